@@ -0,0 +1,344 @@
+package koff
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OffsetsBackupVersion is the version of the OffsetsBackup document written by this package.
+const OffsetsBackupVersion = 1
+
+// OffsetsBackup is a snapshot of the committed offsets of a consumer group.
+type OffsetsBackup struct {
+	Version       int            `json:"version"`
+	ConsumerGroup string         `json:"consumer_group"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Offsets       []BackupOffset `json:"offsets"`
+}
+
+// BackupOffset is a committed offset of a partition in an OffsetsBackup.
+type BackupOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Metadata  string `json:"metadata"`
+}
+
+type backupOffsetsByPartition []BackupOffset
+
+func (b backupOffsetsByPartition) Len() int { return len(b) }
+func (b backupOffsetsByPartition) Less(i, j int) bool {
+	if b[i].Topic != b[j].Topic {
+		return b[i].Topic < b[j].Topic
+	}
+	return b[i].Partition < b[j].Partition
+}
+func (b backupOffsetsByPartition) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+
+// topics returns the committed offsets of the backup grouped by topic.
+func (b *OffsetsBackup) topics() map[string]map[int32]CommittedOffset {
+	res := make(map[string]map[int32]CommittedOffset)
+	for _, o := range b.Offsets {
+		if res[o.Topic] == nil {
+			res[o.Topic] = make(map[int32]CommittedOffset)
+		}
+		res[o.Topic][o.Partition] = CommittedOffset{
+			Offset:   o.Offset,
+			Metadata: o.Metadata,
+		}
+	}
+	return res
+}
+
+// ExportConsumerGroupOffsets takes a snapshot of the committed offsets of the consumer group on the given topics.
+//
+// Partitions on which the consumer group never committed are left out.
+func (k *Koff) ExportConsumerGroupOffsets(consumerGroup string, version OffsetVersion, topics ...string) (*OffsetsBackup, error) {
+	return k.ExportConsumerGroupOffsetsContext(context.Background(), consumerGroup, version, topics...)
+}
+
+// ExportConsumerGroupOffsetsContext is like ExportConsumerGroupOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) ExportConsumerGroupOffsetsContext(ctx context.Context, consumerGroup string, version OffsetVersion, topics ...string) (*OffsetsBackup, error) {
+	res := &OffsetsBackup{
+		Version:       OffsetsBackupVersion,
+		ConsumerGroup: consumerGroup,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	for _, topic := range topics {
+		committed, err := k.GetCommittedOffsetsContext(ctx, consumerGroup, topic, version)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get committed offsets of %q. err=%v", topic, err)
+		}
+
+		for p, c := range committed {
+			if c.Offset < 0 {
+				continue
+			}
+
+			res.Offsets = append(res.Offsets, BackupOffset{
+				Topic:     topic,
+				Partition: p,
+				Offset:    c.Offset,
+				Metadata:  c.Metadata,
+			})
+		}
+	}
+
+	sort.Sort(backupOffsetsByPartition(res.Offsets))
+
+	return res, nil
+}
+
+// WriteJSON writes the backup as an indented JSON document.
+func (b *OffsetsBackup) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+var csvHeader = []string{"topic", "partition", "offset", "metadata"}
+
+// WriteCSV writes the offsets of the backup as CSV with a header line.
+//
+// The CSV format only has the offsets, the consumer group is not part of it.
+func (b *OffsetsBackup) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, o := range b.Offsets {
+		record := []string{
+			o.Topic,
+			strconv.Itoa(int(o.Partition)),
+			strconv.FormatInt(o.Offset, 10),
+			o.Metadata,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// ReadOffsetsBackupJSON reads a backup written by WriteJSON.
+func ReadOffsetsBackupJSON(r io.Reader) (*OffsetsBackup, error) {
+	var b OffsetsBackup
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, err
+	}
+
+	if b.Version != OffsetsBackupVersion {
+		return nil, fmt.Errorf("unsupported offsets backup version %d", b.Version)
+	}
+
+	return &b, nil
+}
+
+// ReadOffsetsBackupCSV reads a backup written by WriteCSV.
+func ReadOffsetsBackupCSV(r io.Reader) (*OffsetsBackup, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 || records[0][0] != csvHeader[0] {
+		return nil, fmt.Errorf("missing CSV header %v", csvHeader)
+	}
+
+	b := &OffsetsBackup{Version: OffsetsBackupVersion}
+	for i, record := range records[1:] {
+		partition, err := strconv.ParseInt(record[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid partition on line %d. err=%v", i+2, err)
+		}
+
+		offset, err := strconv.ParseInt(record[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset on line %d. err=%v", i+2, err)
+		}
+
+		b.Offsets = append(b.Offsets, BackupOffset{
+			Topic:     record[0],
+			Partition: int32(partition),
+			Offset:    offset,
+			Metadata:  record[3],
+		})
+	}
+
+	return b, nil
+}
+
+// OffsetImport is the change of the committed offset of a partition when importing a backup.
+type OffsetImport struct {
+	Current int64
+	New     int64
+
+	// Range is the verdict of checking the new offset against the available range of the partition.
+	Range OffsetRange
+}
+
+// PlanOffsetsImport computes the changes to the committed offsets of the consumer group when importing the backup, without committing anything.
+//
+// Every new offset is checked against the available range of its partition.
+//
+// Returns a map of topics to partitions to offset import.
+func (k *Koff) PlanOffsetsImport(consumerGroup string, version OffsetVersion, backup *OffsetsBackup) (map[string]map[int32]OffsetImport, error) {
+	return k.PlanOffsetsImportContext(context.Background(), consumerGroup, version, backup)
+}
+
+// PlanOffsetsImportContext is like PlanOffsetsImport but honors the deadline and cancellation of ctx.
+func (k *Koff) PlanOffsetsImportContext(ctx context.Context, consumerGroup string, version OffsetVersion, backup *OffsetsBackup) (map[string]map[int32]OffsetImport, error) {
+	res := make(map[string]map[int32]OffsetImport)
+	for topic, offsets := range backup.topics() {
+		var partitions []int32
+		for p := range offsets {
+			partitions = append(partitions, p)
+		}
+
+		oldestOffsets, err := k.GetOldestOffsetsContext(ctx, topic, partitions...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get oldest offsets. err=%v", err)
+		}
+
+		newestOffsets, err := k.GetNewestOffsetsContext(ctx, topic, partitions...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get newest offsets. err=%v", err)
+		}
+
+		cgroupOffsets, err := k.GetConsumerGroupOffsetsContext(ctx, consumerGroup, topic, version, partitions...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get consumer group offsets. err=%v", err)
+		}
+
+		res[topic] = make(map[int32]OffsetImport)
+		for p, c := range offsets {
+			res[topic][p] = OffsetImport{
+				Current: cgroupOffsets[p],
+				New:     c.Offset,
+				Range:   newOffsetRange(c.Offset, oldestOffsets[p], newestOffsets[p]),
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// ImportConsumerGroupOffsets commits the offsets of the backup for the consumer group after validating them like PlanOffsetsImport.
+//
+// Nothing is committed if one of the offsets is out of the available range of its partition.
+// It refuses to commit if the consumer group still has active members and returns ErrGroupHasActiveMembers, or a DescribeGroupError
+// if it can't be described to check them, unless force is true.
+//
+// Returns a map of topics to partitions to offset import.
+func (k *Koff) ImportConsumerGroupOffsets(consumerGroup string, version OffsetVersion, backup *OffsetsBackup, force bool) (map[string]map[int32]OffsetImport, error) {
+	return k.ImportConsumerGroupOffsetsContext(context.Background(), consumerGroup, version, backup, force)
+}
+
+// ImportConsumerGroupOffsetsContext is like ImportConsumerGroupOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) ImportConsumerGroupOffsetsContext(ctx context.Context, consumerGroup string, version OffsetVersion, backup *OffsetsBackup, force bool) (map[string]map[int32]OffsetImport, error) {
+	if !force {
+		if err := k.checkNoActiveMembers(ctx, consumerGroup); err != nil {
+			return nil, err
+		}
+	}
+
+	plan, err := k.PlanOffsetsImportContext(ctx, consumerGroup, version, backup)
+	if err != nil {
+		return nil, err
+	}
+
+	for topic, partitions := range plan {
+		for p, i := range partitions {
+			if i.Range.Verdict != InRange {
+				return plan, fmt.Errorf("offset %d of (%q, %d) is %s", i.New, topic, p, i.Range.Verdict)
+			}
+		}
+	}
+
+	for topic, offsets := range backup.topics() {
+		if err := k.CommitOffsetsContext(ctx, consumerGroup, topic, version, offsets); err != nil {
+			return plan, err
+		}
+	}
+
+	return plan, nil
+}
+
+// ErrGroupHasCommittedOffsets is returned when refusing to overwrite the committed offsets of a consumer group.
+var ErrGroupHasCommittedOffsets = errors.New("consumer group has committed offsets")
+
+// CopyConsumerGroupOffsets commits the offsets of the source consumer group on the given topics under the target consumer group.
+//
+// It refuses to proceed if the target consumer group already committed offsets on one of the topics and returns ErrGroupHasCommittedOffsets,
+// or if it has active members and returns ErrGroupHasActiveMembers, unless overwrite is true.
+// If the target consumer group can't be described to check its members, the offsets are still copied since it has none on the topics.
+//
+// Returns a map of topics to partitions to the committed offsets which were copied, along with a DescribeGroupError
+// if the members of the target consumer group were not checked.
+func (k *Koff) CopyConsumerGroupOffsets(source, target string, version OffsetVersion, overwrite bool, topics ...string) (map[string]map[int32]CommittedOffset, error) {
+	return k.CopyConsumerGroupOffsetsContext(context.Background(), source, target, version, overwrite, topics...)
+}
+
+// CopyConsumerGroupOffsetsContext is like CopyConsumerGroupOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) CopyConsumerGroupOffsetsContext(ctx context.Context, source, target string, version OffsetVersion, overwrite bool, topics ...string) (map[string]map[int32]CommittedOffset, error) {
+	backup, err := k.ExportConsumerGroupOffsetsContext(ctx, source, version, topics...)
+	if err != nil {
+		return nil, err
+	}
+
+	var unchecked *DescribeGroupError
+	if !overwrite {
+		for _, topic := range topics {
+			offsets, err := k.GetConsumerGroupOffsetsContext(ctx, target, topic, version)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get offsets of target consumer group. err=%v", err)
+			}
+
+			for _, offset := range offsets {
+				if offset >= 0 {
+					return nil, ErrGroupHasCommittedOffsets
+				}
+			}
+		}
+
+		err := k.checkNoActiveMembers(ctx, target)
+		if derr, ok := err.(*DescribeGroupError); ok {
+			unchecked = derr
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	res := backup.topics()
+	for topic, offsets := range res {
+		if err := k.CommitOffsetsContext(ctx, target, topic, version, offsets); err != nil {
+			return nil, err
+		}
+	}
+
+	if unchecked != nil {
+		return res, unchecked
+	}
+
+	return res, nil
+}
@@ -0,0 +1,177 @@
+package koff_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestExportConsumerGroupOffsets(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	backup, err := k.ExportConsumerGroupOffsets("myConsumerGroup", koff.KafkaOffsetVersion, "foobar")
+	require.Nil(t, err)
+
+	require.Equal(t, koff.OffsetsBackupVersion, backup.Version)
+	require.Equal(t, "myConsumerGroup", backup.ConsumerGroup)
+	require.Equal(t, []koff.BackupOffset{
+		{Topic: "foobar", Partition: 0, Offset: 800},
+		{Topic: "foobar", Partition: 1, Offset: 8000},
+	}, backup.Offsets)
+}
+
+func TestOffsetsBackupJSON(t *testing.T) {
+	backup := &koff.OffsetsBackup{
+		Version:       koff.OffsetsBackupVersion,
+		ConsumerGroup: "myConsumerGroup",
+		Offsets: []koff.BackupOffset{
+			{Topic: "foobar", Partition: 0, Offset: 800, Metadata: "foo"},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.Nil(t, backup.WriteJSON(&buf))
+
+	backup2, err := koff.ReadOffsetsBackupJSON(&buf)
+	require.Nil(t, err)
+	require.Equal(t, backup, backup2)
+
+	_, err = koff.ReadOffsetsBackupJSON(strings.NewReader(`{"version": 42}`))
+	require.NotNil(t, err)
+}
+
+func TestOffsetsBackupCSV(t *testing.T) {
+	backup := &koff.OffsetsBackup{
+		Version: koff.OffsetsBackupVersion,
+		Offsets: []koff.BackupOffset{
+			{Topic: "foobar", Partition: 0, Offset: 800, Metadata: "foo,bar"},
+			{Topic: "foobar", Partition: 1, Offset: 8000},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.Nil(t, backup.WriteCSV(&buf))
+	require.Equal(t, "topic,partition,offset,metadata\nfoobar,0,800,\"foo,bar\"\nfoobar,1,8000,\n", buf.String())
+
+	backup2, err := koff.ReadOffsetsBackupCSV(&buf)
+	require.Nil(t, err)
+	require.Equal(t, backup, backup2)
+}
+
+func TestImportConsumerGroupOffsets(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	backup := &koff.OffsetsBackup{
+		Version: koff.OffsetsBackupVersion,
+		Offsets: []koff.BackupOffset{
+			{Topic: "foobar", Partition: 0, Offset: 600},
+			{Topic: "foobar", Partition: 1, Offset: 100},
+		},
+	}
+
+	plan, err := k.PlanOffsetsImport("myConsumerGroup", koff.KafkaOffsetVersion, backup)
+	require.Nil(t, err)
+	require.Equal(t, int64(800), plan["foobar"][0].Current)
+	require.Equal(t, int64(600), plan["foobar"][0].New)
+	require.Equal(t, koff.InRange, plan["foobar"][0].Range.Verdict)
+	require.Equal(t, koff.BelowOldest, plan["foobar"][1].Range.Verdict)
+
+	_, err = k.ImportConsumerGroupOffsets("myConsumerGroup", koff.KafkaOffsetVersion, backup, false)
+	require.Equal(t, koff.ErrGroupHasActiveMembers, err)
+
+	_, err = k.ImportConsumerGroupOffsets("myConsumerGroup", koff.KafkaOffsetVersion, backup, true)
+	require.NotNil(t, err)
+
+	backup.Offsets[1].Offset = 6000
+
+	_, err = k.ImportConsumerGroupOffsets("myConsumerGroup", koff.KafkaOffsetVersion, backup, true)
+	require.Nil(t, err)
+}
+
+func TestCopyConsumerGroupOffsets(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	_, err = k.CopyConsumerGroupOffsets("myNewGroup", "myConsumerGroup", koff.KafkaOffsetVersion, false, "foobar")
+	require.Equal(t, koff.ErrGroupHasCommittedOffsets, err)
+
+	// The mock broker describes every group with the members of myConsumerGroup.
+	_, err = k.CopyConsumerGroupOffsets("myConsumerGroup", "myNewGroup", koff.KafkaOffsetVersion, false, "foobar")
+	require.Equal(t, koff.ErrGroupHasActiveMembers, err)
+
+	copied, err := k.CopyConsumerGroupOffsets("myConsumerGroup", "myNewGroup", koff.KafkaOffsetVersion, true, "foobar")
+	require.Nil(t, err)
+	require.Equal(t, map[string]map[int32]koff.CommittedOffset{
+		"foobar": {
+			0: {Offset: 800},
+			1: {Offset: 8000},
+		},
+	}, copied)
+}
+
+func TestCopyConsumerGroupOffsetsUndescribed(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker.Addr(), 1)
+	metadataResponse.SetLeader("foobar", 0, 1)
+
+	offsetFetchResponse := sarama.NewMockOffsetFetchResponse(t)
+	offsetFetchResponse.SetOffset("mySourceGroup", "foobar", 0, 800, "", sarama.ErrNoError)
+	offsetFetchResponse.SetOffset("myZKGroup", "foobar", 0, -1, "", sarama.ErrNoError)
+
+	consumerMetadataResponse := sarama.NewMockConsumerMetadataResponse(t)
+	consumerMetadataResponse.SetCoordinator("mySourceGroup", broker)
+	consumerMetadataResponse.SetCoordinator("myZKGroup", broker)
+
+	// A group committing its offsets to ZooKeeper is unknown to the coordinator.
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":         metadataResponse,
+		"OffsetFetchRequest":      offsetFetchResponse,
+		"ConsumerMetadataRequest": consumerMetadataResponse,
+		"OffsetCommitRequest":     sarama.NewMockOffsetCommitResponse(t),
+		"DescribeGroupsRequest": sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Groups: []*sarama.GroupDescription{
+				{GroupId: "myZKGroup", Err: sarama.ErrNotCoordinatorForConsumer},
+			},
+		}),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_9_0_0
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+
+	copied, err := k.CopyConsumerGroupOffsets("mySourceGroup", "myZKGroup", koff.ZKOffsetVersion, false, "foobar")
+	derr, ok := err.(*koff.DescribeGroupError)
+	require.True(t, ok)
+	require.Equal(t, "myZKGroup", derr.Group)
+	require.Equal(t, map[string]map[int32]koff.CommittedOffset{
+		"foobar": {0: {Offset: 800}},
+	}, copied)
+}
@@ -0,0 +1,230 @@
+package koff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// Keys of the Kafka APIs koff relies on, as found in the ApiVersions response of a broker.
+const (
+	APIKeyListOffsets    int16 = 2
+	APIKeyOffsetCommit   int16 = 8
+	APIKeyOffsetFetch    int16 = 9
+	APIKeyDescribeGroups int16 = 15
+)
+
+// kafkaVersions lists the Kafka versions known by sarama which can be told apart with an ApiVersions response, newest first,
+// along with the minimum max version of the APIs which changed in them.
+var kafkaVersions = []struct {
+	version sarama.KafkaVersion
+	apis    map[int16]int16
+}{
+	{sarama.V0_10_2_0, map[int16]int16{APIKeyListOffsets: 1, APIKeyOffsetFetch: 2}},
+	{sarama.V0_10_1_0, map[int16]int16{APIKeyListOffsets: 1}},
+	{sarama.V0_10_0_0, nil},
+}
+
+// ErrNoAPIVersions is returned when negotiating versions without the API versions of any broker,
+// either because none could be queried or because they are all older than Kafka 0.10.
+var ErrNoAPIVersions = errors.New("no API versions to negotiate with")
+
+// APIVersionRange is the inclusive range of versions of an API supported by a broker.
+type APIVersionRange struct {
+	Min int16
+	Max int16
+}
+
+// BrokerAPIVersions describes a broker and the versions of the APIs it supports.
+type BrokerAPIVersions struct {
+	ID   int32
+	Addr string
+
+	// Versions maps API keys to the supported versions. It is nil if the broker could not be queried.
+	Versions map[int16]APIVersionRange
+	// Legacy is true if the broker accepted the connection but rejected the ApiVersions request, like brokers older than Kafka 0.10.
+	Legacy bool
+}
+
+// GetBrokerAPIVersions sends an ApiVersions request to every broker of the cluster.
+//
+// The brokers are queried on their own connection so the request is sent whatever the Kafka version of the client configuration is.
+// Brokers older than Kafka 0.10 don't know the request and close the connection.
+//
+// Returns a map of broker IDs to API versions. Every broker is in the map, and if some of them failed
+// their Versions is nil and the map is returned along with a BrokerErrors. The brokers which rejected the request are marked as Legacy.
+func (k *Koff) GetBrokerAPIVersions() (map[int32]BrokerAPIVersions, error) {
+	return k.GetBrokerAPIVersionsContext(context.Background())
+}
+
+// GetBrokerAPIVersionsContext is like GetBrokerAPIVersions but honors the deadline and cancellation of ctx.
+func (k *Koff) GetBrokerAPIVersionsContext(ctx context.Context) (map[int32]BrokerAPIVersions, error) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		res  = make(map[int32]BrokerAPIVersions)
+		errs = make(BrokerErrors)
+	)
+
+	for _, broker := range k.client.Brokers() {
+		wg.Add(1)
+		go func(broker *sarama.Broker) {
+			defer wg.Done()
+
+			versions, legacy, err := k.getAPIVersions(ctx, broker.Addr())
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			res[broker.ID()] = BrokerAPIVersions{
+				ID:       broker.ID(),
+				Addr:     broker.Addr(),
+				Versions: versions,
+				Legacy:   legacy,
+			}
+			if err != nil {
+				errs[broker.ID()] = err
+			}
+		}(broker)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return res, errs
+	}
+
+	return res, nil
+}
+
+// getAPIVersions queries the API versions of the broker at addr. legacy is true if the broker could be connected to but failed the request.
+func (k *Koff) getAPIVersions(ctx context.Context, addr string) (map[int16]APIVersionRange, bool, error) {
+	// sarama refuses to send ApiVersions requests unless the configuration is at least Kafka 0.10.
+	conf := *k.client.Config()
+	conf.Version = sarama.V0_10_0_0
+
+	broker := sarama.NewBroker(addr)
+	defer broker.Close()
+
+	err := withContext(ctx, func() error {
+		if err := broker.Open(&conf); err != nil {
+			return err
+		}
+
+		_, err := broker.Connected()
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to connect to broker. err=%v", err)
+	}
+
+	var resp *sarama.ApiVersionsResponse
+	err = withContext(ctx, func() (err error) {
+		resp, err = broker.ApiVersions(&sarama.ApiVersionsRequest{})
+		return err
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to get API versions, the broker may be older than Kafka 0.10. err=%v", err)
+	}
+
+	if resp.Err != sarama.ErrNoError {
+		return nil, false, fmt.Errorf("unable to get API versions. err=%v", resp.Err)
+	}
+
+	res := make(map[int16]APIVersionRange)
+	for _, block := range resp.ApiVersions {
+		res[block.ApiKey] = APIVersionRange{Min: block.MinVersion, Max: block.MaxVersion}
+	}
+
+	return res, false, nil
+}
+
+// HighestKafkaVersion returns the highest Kafka version known by sarama whose APIs are supported by all the brokers.
+//
+// Legacy brokers cap the version at Kafka 0.9, the newest one which has no ApiVersions request.
+// The other brokers without API versions, because they could not be queried, are ignored. If there is none left ErrNoAPIVersions is returned.
+func HighestKafkaVersion(brokers map[int32]BrokerAPIVersions) (sarama.KafkaVersion, error) {
+	var (
+		res   sarama.KafkaVersion
+		found bool
+	)
+
+	for _, b := range brokers {
+		var version sarama.KafkaVersion
+		switch {
+		case b.Legacy:
+			version = sarama.V0_9_0_0
+		case b.Versions == nil:
+			continue
+		default:
+			version = sarama.V0_10_0_0
+			for _, kv := range kafkaVersions {
+				if supportsAPIs(b.Versions, kv.apis) {
+					version = kv.version
+					break
+				}
+			}
+		}
+
+		if !found || res.IsAtLeast(version) {
+			res = version
+			found = true
+		}
+	}
+
+	if !found {
+		return res, ErrNoAPIVersions
+	}
+
+	return res, nil
+}
+
+// HighestOffsetVersion returns the highest OffsetVersion supported by both the OffsetFetch and OffsetCommit APIs of all the brokers.
+//
+// Legacy brokers cap the version at ZKOffsetVersion, the other brokers without API versions are ignored like in HighestKafkaVersion.
+func HighestOffsetVersion(brokers map[int32]BrokerAPIVersions) (OffsetVersion, error) {
+	var (
+		res   = KafkaOffsetVersion
+		found bool
+	)
+
+	for _, b := range brokers {
+		if b.Legacy {
+			res = ZKOffsetVersion
+			found = true
+			continue
+		}
+		if b.Versions == nil {
+			continue
+		}
+		found = true
+
+		for _, key := range []int16{APIKeyOffsetFetch, APIKeyOffsetCommit} {
+			if v, ok := b.Versions[key]; !ok || v.Max < int16(res) {
+				res = ZKOffsetVersion
+			}
+		}
+	}
+
+	if !found {
+		return res, ErrNoAPIVersions
+	}
+
+	return res, nil
+}
+
+// supportsAPIs reports whether the versions go at least up to the given max version of each API.
+func supportsAPIs(versions map[int16]APIVersionRange, apis map[int16]int16) bool {
+	for key, max := range apis {
+		if v, ok := versions[key]; !ok || v.Max < max {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package koff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestGetBrokerAPIVersions(t *testing.T) {
+	broker1 := sarama.NewMockBroker(t, 1)
+	defer broker1.Close()
+
+	metadataResponse := &sarama.MetadataResponse{}
+	metadataResponse.AddBroker(broker1.Addr(), 1)
+	metadataResponse.AddBroker("localhost:1", 2)
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockWrapper(metadataResponse),
+		"ApiVersionsRequest": sarama.NewMockWrapper(&sarama.ApiVersionsResponse{
+			ApiVersions: []*sarama.ApiVersionsResponseBlock{
+				{ApiKey: koff.APIKeyListOffsets, MinVersion: 0, MaxVersion: 1},
+				{ApiKey: koff.APIKeyOffsetFetch, MinVersion: 0, MaxVersion: 2},
+			},
+		}),
+	})
+
+	client, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+
+	versions, err := k.GetBrokerAPIVersions()
+	require.NotNil(t, err)
+
+	errs, ok := err.(koff.BrokerErrors)
+	require.True(t, ok)
+	require.Equal(t, 1, len(errs))
+	require.NotNil(t, errs[2])
+
+	require.Equal(t, map[int32]koff.BrokerAPIVersions{
+		1: {
+			ID:   1,
+			Addr: broker1.Addr(),
+			Versions: map[int16]koff.APIVersionRange{
+				koff.APIKeyListOffsets: {Min: 0, Max: 1},
+				koff.APIKeyOffsetFetch: {Min: 0, Max: 2},
+			},
+		},
+		2: {ID: 2, Addr: "localhost:1"},
+	}, versions)
+}
+
+func TestGetBrokerAPIVersionsLegacy(t *testing.T) {
+	broker1 := sarama.NewMockBroker(t, 1)
+	defer broker1.Close()
+
+	// The second broker accepts connections but never answers the ApiVersions request, like Kafka 0.9.
+	broker2 := sarama.NewMockBroker(t, 2)
+	defer broker2.Close()
+
+	metadataResponse := &sarama.MetadataResponse{}
+	metadataResponse.AddBroker(broker1.Addr(), 1)
+	metadataResponse.AddBroker(broker2.Addr(), 2)
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockWrapper(metadataResponse),
+		"ApiVersionsRequest": sarama.NewMockWrapper(&sarama.ApiVersionsResponse{
+			ApiVersions: []*sarama.ApiVersionsResponseBlock{
+				{ApiKey: koff.APIKeyListOffsets, MinVersion: 0, MaxVersion: 1},
+				{ApiKey: koff.APIKeyOffsetCommit, MinVersion: 0, MaxVersion: 2},
+				{ApiKey: koff.APIKeyOffsetFetch, MinVersion: 0, MaxVersion: 2},
+			},
+		}),
+	})
+
+	config := sarama.NewConfig()
+	config.Net.ReadTimeout = 100 * time.Millisecond
+
+	client, err := sarama.NewClient([]string{broker1.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+
+	versions, err := k.GetBrokerAPIVersions()
+	require.NotNil(t, err)
+	require.False(t, versions[1].Legacy)
+	require.True(t, versions[2].Legacy)
+
+	version, err := koff.HighestKafkaVersion(versions)
+	require.Nil(t, err)
+	require.Equal(t, sarama.V0_9_0_0, version)
+
+	offsetVersion, err := koff.HighestOffsetVersion(versions)
+	require.Nil(t, err)
+	require.Equal(t, koff.ZKOffsetVersion, offsetVersion)
+}
+
+func TestHighestKafkaVersion(t *testing.T) {
+	v0_10_0 := map[int16]koff.APIVersionRange{
+		koff.APIKeyListOffsets:  {Min: 0, Max: 0},
+		koff.APIKeyOffsetCommit: {Min: 0, Max: 2},
+		koff.APIKeyOffsetFetch:  {Min: 0, Max: 1},
+	}
+	v0_10_2 := map[int16]koff.APIVersionRange{
+		koff.APIKeyListOffsets:  {Min: 0, Max: 1},
+		koff.APIKeyOffsetCommit: {Min: 0, Max: 2},
+		koff.APIKeyOffsetFetch:  {Min: 0, Max: 2},
+	}
+
+	brokers := map[int32]koff.BrokerAPIVersions{
+		1: {ID: 1, Versions: v0_10_2},
+		2: {ID: 2},
+	}
+
+	version, err := koff.HighestKafkaVersion(brokers)
+	require.Nil(t, err)
+	require.Equal(t, sarama.V0_10_2_0, version)
+
+	offsetVersion, err := koff.HighestOffsetVersion(brokers)
+	require.Nil(t, err)
+	require.Equal(t, koff.KafkaOffsetVersion, offsetVersion)
+
+	brokers[3] = koff.BrokerAPIVersions{ID: 3, Versions: v0_10_0}
+
+	version, err = koff.HighestKafkaVersion(brokers)
+	require.Nil(t, err)
+	require.Equal(t, sarama.V0_10_0_0, version)
+
+	_, err = koff.HighestKafkaVersion(map[int32]koff.BrokerAPIVersions{2: {ID: 2}, 4: {ID: 4, Legacy: true}})
+	require.Nil(t, err)
+
+	_, err = koff.HighestKafkaVersion(map[int32]koff.BrokerAPIVersions{2: {ID: 2}})
+	require.Equal(t, koff.ErrNoAPIVersions, err)
+
+	_, err = koff.HighestOffsetVersion(nil)
+	require.Equal(t, koff.ErrNoAPIVersions, err)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/vrischmann/koff"
+)
+
+// formatVersions formats the versions of the API supported by a broker, - if it doesn't support it.
+func formatVersions(versions map[int16]koff.APIVersionRange, key int16) string {
+	v, ok := versions[key]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%d-%d", v.Min, v.Max)
+}
+
+// listBrokers shows every broker of the cluster with the versions of the APIs koff uses it supports.
+func listBrokers() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	brokers, err := k.GetBrokerAPIVersionsContext(ctx)
+	errs, ok := err.(koff.BrokerErrors)
+	if err != nil && !ok {
+		return err
+	}
+
+	var keys []int
+	for id := range brokers {
+		keys = append(keys, int(id))
+	}
+
+	sort.Ints(keys)
+
+	res := &brokersResult{Brokers: []brokerRecord{}}
+	for _, id := range keys {
+		b := brokers[int32(id)]
+
+		rec := brokerRecord{ID: b.ID, Addr: b.Addr}
+		if err, ok := errs[b.ID]; ok {
+			rec.Error = err.Error()
+			res.Unreachable++
+		} else {
+			rec.ListOffsets = formatVersions(b.Versions, koff.APIKeyListOffsets)
+			rec.OffsetFetch = formatVersions(b.Versions, koff.APIKeyOffsetFetch)
+			rec.OffsetCommit = formatVersions(b.Versions, koff.APIKeyOffsetCommit)
+			rec.DescribeGroups = formatVersions(b.Versions, koff.APIKeyDescribeGroups)
+		}
+		res.Brokers = append(res.Brokers, rec)
+	}
+
+	return render(res)
+}
+
+func brokersCommand() error {
+	if err := fsBr.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return listBrokers()
+}
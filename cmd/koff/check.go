@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/koff"
+)
+
+// The states and exit codes of a monitoring plugin.
+const (
+	checkOK = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+var checkStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// The metrics the thresholds of the check apply to.
+const (
+	checkTotalLag   = "total"
+	checkMaxLag     = "max"
+	checkSecondsLag = "seconds"
+)
+
+func checkThresholds() error {
+	switch flCheckMetric {
+	case checkTotalLag, checkMaxLag, checkSecondsLag:
+	default:
+		return fmt.Errorf("%q unknown metric", flCheckMetric)
+	}
+
+	if flWarning <= 0 && flCritical <= 0 {
+		return errors.New("neither warning nor critical threshold is set")
+	}
+
+	if flWarning > 0 && flCritical > 0 && flWarning > flCritical {
+		return errors.New("warning threshold is above the critical threshold")
+	}
+
+	return nil
+}
+
+// thresholdState returns the state of the value according to the thresholds, a threshold of 0 being disabled.
+func thresholdState(value float64) int {
+	switch {
+	case flCritical > 0 && value >= flCritical:
+		return checkCritical
+	case flWarning > 0 && value >= flWarning:
+		return checkWarning
+	default:
+		return checkOK
+	}
+}
+
+func formatThreshold(v float64) string {
+	if v <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// perfdata formats a performance data item. The thresholds are only set on the metric they apply to.
+func perfdata(label string, value float64, uom string, thresholds bool) string {
+	var warning, critical string
+	if thresholds {
+		warning, critical = formatThreshold(flWarning), formatThreshold(flCritical)
+	}
+
+	return fmt.Sprintf("%s=%s%s;%s;%s;0", label, strconv.FormatFloat(value, 'f', -1, 64), uom, warning, critical)
+}
+
+// checkLag evaluates the lag of the consumer groups against the thresholds, the lag being summed over all the selected groups and topics.
+//
+// Returns the state and the one line summary with its perfdata.
+func checkLag() (int, string) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return checkUnknown, err.Error()
+	}
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return checkUnknown, err.Error()
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return checkUnknown, err.Error()
+	}
+
+	var (
+		total, max int64
+		maxTime    time.Duration
+	)
+
+	add := func(newest, committed int64) {
+		lag, ok := koff.Lag(newest, committed)
+		if !ok {
+			return
+		}
+
+		total += lag
+		if lag > max {
+			max = lag
+		}
+	}
+
+	for _, group := range groups {
+		for _, t := range targets {
+			if flCheckMetric == checkSecondsLag {
+				lags, err := k.GetTimeLagContext(ctx, group, t.topic, flVersion, t.partitions...)
+				if err != nil {
+					return checkUnknown, err.Error()
+				}
+
+				for _, l := range lags {
+					add(l.Newest, l.Committed)
+					if l.Lag > maxTime {
+						maxTime = l.Lag
+					}
+				}
+				continue
+			}
+
+			newest, err := k.GetNewestOffsetsContext(ctx, t.topic, t.partitions...)
+			if err != nil {
+				return checkUnknown, err.Error()
+			}
+
+			committed, err := k.GetConsumerGroupOffsetsContext(ctx, group, t.topic, flVersion, t.partitions...)
+			if err != nil {
+				return checkUnknown, err.Error()
+			}
+
+			for p, c := range committed {
+				add(newest[p], c)
+			}
+		}
+	}
+
+	var state int
+	switch flCheckMetric {
+	case checkTotalLag:
+		state = thresholdState(float64(total))
+	case checkMaxLag:
+		state = thresholdState(float64(max))
+	case checkSecondsLag:
+		state = thresholdState(maxTime.Seconds())
+	}
+
+	summary := fmt.Sprintf("%s on %s: total lag %d, max partition lag %d", flConsumerGroup, flTopic, total, max)
+	perf := []string{
+		perfdata("total_lag", float64(total), "", flCheckMetric == checkTotalLag),
+		perfdata("max_lag", float64(max), "", flCheckMetric == checkMaxLag),
+	}
+	if flCheckMetric == checkSecondsLag {
+		summary += fmt.Sprintf(", max lag %s", maxTime/time.Second*time.Second)
+		perf = append(perf, perfdata("lag_seconds", maxTime.Seconds(), "s", true))
+	}
+
+	return state, summary + " | " + strings.Join(perf, " ")
+}
+
+// checkCommand runs the check and prints its result following the monitoring plugin conventions.
+//
+// Returns the exit code of the check.
+func checkCommand() int {
+	state, summary := func() (int, string) {
+		if err := fsCheck.Parse(flag.Args()[1:]); err != nil {
+			return checkUnknown, err.Error()
+		}
+
+		if err := checkFlags(); err != nil {
+			return checkUnknown, err.Error()
+		}
+
+		if err := checkThresholds(); err != nil {
+			return checkUnknown, err.Error()
+		}
+
+		if err := initSarama(); err != nil {
+			return checkUnknown, err.Error()
+		}
+		defer client.Close()
+
+		return checkLag()
+	}()
+
+	fmt.Printf("KOFF %s - %s\n", checkStates[state], summary)
+
+	return state
+}
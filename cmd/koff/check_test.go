@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdState(t *testing.T) {
+	defer func(warning, critical float64) {
+		flWarning, flCritical = warning, critical
+	}(flWarning, flCritical)
+
+	flWarning, flCritical = 100, 1000
+
+	require.Equal(t, checkOK, thresholdState(0))
+	require.Equal(t, checkOK, thresholdState(99))
+	require.Equal(t, checkWarning, thresholdState(100))
+	require.Equal(t, checkWarning, thresholdState(999))
+	require.Equal(t, checkCritical, thresholdState(1000))
+
+	flWarning = 0
+	require.Equal(t, checkOK, thresholdState(999))
+	require.Equal(t, checkCritical, thresholdState(5000))
+
+	flWarning, flCritical = 100, 0
+	require.Equal(t, checkWarning, thresholdState(5000))
+}
+
+func TestPerfdata(t *testing.T) {
+	defer func(warning, critical float64) {
+		flWarning, flCritical = warning, critical
+	}(flWarning, flCritical)
+
+	flWarning, flCritical = 100, 1000.5
+
+	require.Equal(t, "total_lag=42;100;1000.5;0", perfdata("total_lag", 42, "", true))
+	require.Equal(t, "max_lag=7;;;0", perfdata("max_lag", 7, "", false))
+	require.Equal(t, "lag_seconds=1.5s;100;1000.5;0", perfdata("lag_seconds", 1.5, "s", true))
+
+	flWarning = 0
+	require.Equal(t, "total_lag=0;;1000.5;0", perfdata("total_lag", 0, "", true))
+}
@@ -4,39 +4,249 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/vrischmann/koff"
 )
 
+// timeValue is a flag.Value accepting either a RFC3339 time or a duration relative to now, like -2h.
+type timeValue struct {
+	time.Time
+}
+
+func (v *timeValue) Set(s string) error {
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.Time = time.Now().Add(d)
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+	v.Time = t
+
+	return nil
+}
+
+func (v *timeValue) String() string {
+	if v.IsZero() {
+		return ""
+	}
+	return v.Format(time.RFC3339)
+}
+
+// kafkaVersionNames maps the Kafka versions accepted by -kafka-version to the versions known by sarama.
+var kafkaVersionNames = map[string]sarama.KafkaVersion{
+	"0.8.2.0":  sarama.V0_8_2_0,
+	"0.8.2.1":  sarama.V0_8_2_1,
+	"0.8.2.2":  sarama.V0_8_2_2,
+	"0.9.0.0":  sarama.V0_9_0_0,
+	"0.9.0.1":  sarama.V0_9_0_1,
+	"0.10.0.0": sarama.V0_10_0_0,
+	"0.10.0.1": sarama.V0_10_0_1,
+	"0.10.1.0": sarama.V0_10_1_0,
+	"0.10.2.0": sarama.V0_10_2_0,
+}
+
+// kafkaVersionValue is a flag.Value accepting a Kafka version like 0.10.1.0.
+type kafkaVersionValue struct {
+	name    string
+	version sarama.KafkaVersion
+}
+
+func (v *kafkaVersionValue) Set(s string) error {
+	version, ok := kafkaVersionNames[s]
+	if !ok {
+		return fmt.Errorf("%q unknown Kafka version", s)
+	}
+	v.name = s
+	v.version = version
+
+	return nil
+}
+
+func (v *kafkaVersionValue) String() string {
+	return v.name
+}
+
+// offsetVersionValue is the -V flag. It sets flVersion and records that it was set, so it is not negotiated with the brokers.
+type offsetVersionValue struct {
+	set bool
+}
+
+func (v *offsetVersionValue) Set(s string) error {
+	if err := flVersion.Set(s); err != nil {
+		return err
+	}
+	v.set = true
+
+	return nil
+}
+
+func (v *offsetVersionValue) String() string {
+	if !v.set {
+		return ""
+	}
+	return flVersion.String()
+}
+
 var (
 	flBroker        string
+	flOutput        string
+	flTemplate      string
+	flTemplateFile  string
+	flTimeout       time.Duration
+	flKafkaVersion  kafkaVersionValue
 	flConsumerGroup string
 	flVersion       koff.OffsetVersion
+	flOffsetVersion offsetVersionValue
 	flTopic         string
-	flPartition     int
+	flPartition     string
+	flOffset        int64
+	flTime          timeValue
+	flTimeLag       bool
+	flWatch         time.Duration
+	flToEarliest    bool
+	flToLatest      bool
+	flShiftBy       int64
+	flExecute       bool
+	flForce         bool
+	flFile          string
+	flFormat        string
+	flTargetGroup   string
+	flOverwrite     bool
+	flListen        string
+	flInterval      time.Duration
+	flSamples       int
+	flSampleEvery   time.Duration
+	flCheckMetric   string
+	flWarning       float64
+	flCritical      float64
+	flWorkers       int
+	flCluster       bool
 
 	fsGCGO  = flag.NewFlagSet("gcgo", flag.ContinueOnError)
 	fsGO    = flag.NewFlagSet("go", flag.ContinueOnError)
 	fsDrift = flag.NewFlagSet("drift", flag.ContinueOnError)
+	fsCO    = flag.NewFlagSet("check-offset", flag.ContinueOnError)
+	fsDG    = flag.NewFlagSet("describe-group", flag.ContinueOnError)
+	fsGOA   = flag.NewFlagSet("get-offset-at", flag.ContinueOnError)
+	fsRO    = flag.NewFlagSet("reset-offsets", flag.ContinueOnError)
+	fsEO    = flag.NewFlagSet("export-offsets", flag.ContinueOnError)
+	fsIO    = flag.NewFlagSet("import-offsets", flag.ContinueOnError)
+	fsCG    = flag.NewFlagSet("copy-group", flag.ContinueOnError)
+	fsServe = flag.NewFlagSet("serve", flag.ContinueOnError)
+	fsSt    = flag.NewFlagSet("status", flag.ContinueOnError)
+	fsCheck = flag.NewFlagSet("check", flag.ContinueOnError)
+	fsOv    = flag.NewFlagSet("overview", flag.ContinueOnError)
+	fsTS    = flag.NewFlagSet("topic-stats", flag.ContinueOnError)
+	fsDT    = flag.NewFlagSet("describe-topic", flag.ContinueOnError)
+	fsBr    = flag.NewFlagSet("brokers", flag.ContinueOnError)
 )
 
 func init() {
 	flag.Usage = printUsage
 
 	flag.StringVar(&flBroker, "b", "", "The broker to use")
+	flag.StringVar(&flOutput, "o", outputTable, "The output format: table, json, ndjson, csv or tsv")
+	flag.StringVar(&flOutput, "output", outputTable, "The output format: table, json, ndjson, csv or tsv")
+	flag.StringVar(&flTemplate, "template", "", "The Go template to render the result with, overrides the output format")
+	flag.StringVar(&flTemplateFile, "template-file", "", "The file containing the Go template to render the result with")
+	flag.DurationVar(&flTimeout, "timeout", 0, "The timeout of the requests to Kafka, 0 to disable")
+	flag.Var(&flKafkaVersion, "kafka-version", "The Kafka version to speak, like 0.10.1.0. Negotiated with the brokers if not set")
+
+	fsGCGO.StringVar(&flConsumerGroup, "c", "", "The consumer groups: names, globs or /regexps/ separated by commas")
+	fsGCGO.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsGCGO.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsGCGO.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+
+	fsGO.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsGO.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+
+	fsDrift.StringVar(&flConsumerGroup, "c", "", "The consumer groups: names, globs or /regexps/ separated by commas")
+	fsDrift.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsDrift.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsDrift.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+	fsDrift.BoolVar(&flTimeLag, "time", false, "Report the drift as a duration using the message timestamps")
+	fsDrift.DurationVar(&flWatch, "watch", 0, "Sample the drift at this interval and show the produce and consume rates")
+
+	fsCO.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsCO.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+	fsCO.Int64Var(&flOffset, "O", -1, "The offset to check")
+
+	fsDG.StringVar(&flConsumerGroup, "c", "", "The consumer groups: names, globs or /regexps/ separated by commas")
+
+	fsGOA.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsGOA.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+	fsGOA.Var(&flTime, "T", "The time, either RFC3339 or relative to now like -2h")
+
+	fsRO.StringVar(&flConsumerGroup, "c", "", "The consumer groups: names, globs or /regexps/ separated by commas")
+	fsRO.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsRO.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsRO.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+	fsRO.BoolVar(&flToEarliest, "to-earliest", false, "Reset to the oldest offset")
+	fsRO.BoolVar(&flToLatest, "to-latest", false, "Reset to the end of the log")
+	fsRO.Int64Var(&flOffset, "to-offset", -1, "Reset to the given offset")
+	fsRO.Int64Var(&flShiftBy, "shift-by", 0, "Shift the committed offset by N, negative to rewind")
+	fsRO.Var(&flTime, "to-datetime", "Reset to the given time, either RFC3339 or relative to now like -2h")
+	fsRO.BoolVar(&flExecute, "execute", false, "Commit the new offsets instead of only printing them")
+	fsRO.BoolVar(&flForce, "force", false, "Commit even if the consumer group has active members")
+
+	fsEO.StringVar(&flConsumerGroup, "c", "", "The consumer group")
+	fsEO.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsEO.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsEO.StringVar(&flFile, "f", "-", "The file to write to, - for stdout")
+	fsEO.StringVar(&flFormat, "format", "", "The format, json or csv. Guessed from the file extension if not set")
+
+	fsIO.StringVar(&flConsumerGroup, "c", "", "The consumer group, defaults to the one of the backup")
+	fsIO.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsIO.StringVar(&flFile, "f", "-", "The file to read from, - for stdin")
+	fsIO.StringVar(&flFormat, "format", "", "The format, json or csv. Guessed from the file extension if not set")
+	fsIO.BoolVar(&flExecute, "execute", false, "Commit the new offsets instead of only printing them")
+	fsIO.BoolVar(&flForce, "force", false, "Commit even if the consumer group has active members")
+
+	fsCG.StringVar(&flConsumerGroup, "c", "", "The source consumer group")
+	fsCG.StringVar(&flTargetGroup, "target", "", "The target consumer group")
+	fsCG.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsCG.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsCG.BoolVar(&flOverwrite, "overwrite", false, "Copy even if the target consumer group has active members or committed offsets")
+
+	fsServe.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsServe.StringVar(&flListen, "l", ":9308", "The address to serve the metrics on")
+	fsServe.DurationVar(&flInterval, "interval", 30*time.Second, "The interval between two collections")
+
+	fsSt.StringVar(&flConsumerGroup, "c", "", "The consumer groups: names, globs or /regexps/ separated by commas")
+	fsSt.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsSt.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsSt.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+	fsSt.IntVar(&flSamples, "samples", 5, "The number of samples to evaluate the status on")
+	fsSt.DurationVar(&flSampleEvery, "every", 10*time.Second, "The interval between two samples")
+
+	fsCheck.StringVar(&flConsumerGroup, "c", "", "The consumer groups: names, globs or /regexps/ separated by commas")
+	fsCheck.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsCheck.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsCheck.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+	fsCheck.StringVar(&flCheckMetric, "metric", "total", "The metric the thresholds apply to: total, max or seconds")
+	fsCheck.Float64Var(&flWarning, "warning", 0, "The warning threshold, 0 to disable")
+	fsCheck.Float64Var(&flCritical, "critical", 0, "The critical threshold, 0 to disable")
 
-	fsGCGO.StringVar(&flConsumerGroup, "c", "", "The consumer group")
-	fsGCGO.Var(&flVersion, "V", "The Kafka offset version")
-	fsGCGO.StringVar(&flTopic, "t", "", "The topic")
-	fsGCGO.IntVar(&flPartition, "p", -1, "The partition")
+	fsOv.StringVar(&flConsumerGroup, "c", "", "The consumer groups: names, globs or /regexps/ separated by commas. All of them if not set")
+	fsOv.Var(&flOffsetVersion, "V", "The Kafka offset version, 0 for ZooKeeper or 1 for Kafka. Negotiated with the brokers if not set")
+	fsOv.IntVar(&flWorkers, "workers", koff.DefaultLagMatrixWorkers, "The number of consumer groups and topics queried at the same time")
 
-	fsGO.StringVar(&flTopic, "t", "", "The topic")
-	fsGO.IntVar(&flPartition, "p", -1, "The partition")
+	fsTS.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsTS.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
 
-	fsDrift.StringVar(&flConsumerGroup, "c", "", "The consumer group")
-	fsDrift.Var(&flVersion, "V", "The Kafka offset version")
-	fsDrift.StringVar(&flTopic, "t", "", "The topic")
-	fsDrift.IntVar(&flPartition, "p", -1, "The partition")
+	fsDT.StringVar(&flTopic, "t", "", "The topics: names, globs or /regexps/ separated by commas")
+	fsDT.StringVar(&flPartition, "p", "", "The partitions, like 0-3,7. All of them if not set")
+	fsDT.BoolVar(&flCluster, "cluster", false, "Show the offline and under replicated partitions of every topic instead")
 }
 
 func printUsage() {
@@ -49,4 +259,33 @@ func printUsage() {
 	fsGO.PrintDefaults()
 	fmt.Fprintf(os.Stderr, "\ndrift, d\n")
 	fsDrift.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ncheck-offset, co\n")
+	fsCO.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nlist-groups, lg\n")
+	fmt.Fprintf(os.Stderr, "\ndescribe-group, dg\n")
+	fsDG.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nget-offset-at, goa\n")
+	fsGOA.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nreset-offsets, ro\n")
+	fsRO.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nexport-offsets, eo\n")
+	fsEO.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nimport-offsets, io\n")
+	fsIO.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ncopy-group, cg\n")
+	fsCG.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nserve\n")
+	fsServe.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nstatus, st\n")
+	fsSt.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ncheck\n")
+	fsCheck.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\noverview, ov\n")
+	fsOv.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ntopic-stats, ts\n")
+	fsTS.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ndescribe-topic, dt\n")
+	fsDT.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nbrokers\n")
+	fsBr.PrintDefaults()
 }
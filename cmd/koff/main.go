@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/vrischmann/koff"
@@ -18,6 +23,21 @@ const (
 	cmdGetOffset command = iota
 	cmdGetConsumerGroupOffset
 	cmdDrift
+	cmdCheckOffset
+	cmdListGroups
+	cmdDescribeGroup
+	cmdGetOffsetAt
+	cmdResetOffsets
+	cmdExportOffsets
+	cmdImportOffsets
+	cmdCopyGroup
+	cmdServe
+	cmdStatus
+	cmdCheck
+	cmdOverview
+	cmdTopicStats
+	cmdDescribeTopic
+	cmdBrokers
 )
 
 var (
@@ -25,172 +45,1319 @@ var (
 	cmd    command
 
 	client sarama.Client
+
+	resetStrategy koff.ResetStrategy
 )
 
-func initSarama() (err error) {
-	config := sarama.NewConfig()
-	config.ClientID = "koff"
-	config.Consumer.Return.Errors = false
+// fallbackKafkaVersion returns the Kafka version to use when it can't be negotiated with the brokers, the lowest one the command needs.
+func fallbackKafkaVersion() sarama.KafkaVersion {
+	switch {
+	case cmd == cmdGetOffsetAt:
+		// Offset lookup by timestamp needs at least Kafka 0.10.1
+		return sarama.V0_10_1_0
+	case cmd == cmdResetOffsets && !flTime.IsZero():
+		// Offset lookup by timestamp needs at least Kafka 0.10.1
+		return sarama.V0_10_1_0
+	case cmd == cmdDrift && flTimeLag, cmd == cmdCheck && flCheckMetric == checkSecondsLag:
+		// Message timestamps need at least Kafka 0.10
+		return sarama.V0_10_0_0
+	default:
+		// The consumer group APIs need at least Kafka 0.9
+		return sarama.V0_9_0_0
+	}
+}
+
+// negotiateVersions sends an ApiVersions request to every broker of the cluster and returns the highest Kafka version
+// and offset version they all support.
+//
+// Brokers which rejected the request cap the versions at Kafka 0.9 and the offset version 0,
+// the unreachable ones are logged and left out of the negotiation.
+func negotiateVersions(client sarama.Client) (sarama.KafkaVersion, koff.OffsetVersion, error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	brokers, err := k.GetBrokerAPIVersionsContext(ctx)
+	errs, ok := err.(koff.BrokerErrors)
+	if err != nil && !ok {
+		return sarama.KafkaVersion{}, 0, err
+	}
+
+	version, err := koff.HighestKafkaVersion(brokers)
+	if err != nil {
+		return sarama.KafkaVersion{}, 0, err
+	}
+
+	offsetVersion, err := koff.HighestOffsetVersion(brokers)
+	if err != nil {
+		return sarama.KafkaVersion{}, 0, err
+	}
+
+	if len(errs) > 0 {
+		log.Printf("unable to get the API versions of some brokers, the unreachable ones are left out of the negotiation. err=%v", errs)
+	}
+
+	return version, offsetVersion, nil
+}
+
+func initSarama() (err error) {
+	config := sarama.NewConfig()
+	config.ClientID = "koff"
+	config.Consumer.Return.Errors = false
+	config.Version = fallbackKafkaVersion()
+	if flKafkaVersion.name != "" {
+		config.Version = flKafkaVersion.version
+	}
+
+	client, err = sarama.NewClient([]string{flBroker}, config)
+	if err != nil {
+		return err
+	}
+
+	if flKafkaVersion.name != "" && flOffsetVersion.set {
+		return nil
+	}
+
+	version, offsetVersion, err := negotiateVersions(client)
+	if err != nil {
+		log.Printf("unable to negotiate the protocol versions with the brokers, set them with -kafka-version and -V. err=%v", err)
+		if !flOffsetVersion.set {
+			log.Printf("using the default offset version %s", flVersion)
+		}
+		return nil
+	}
+
+	if !flOffsetVersion.set {
+		flVersion = offsetVersion
+	}
+
+	if flKafkaVersion.name != "" || version == config.Version {
+		return nil
+	}
+
+	// The brokers opened by the client keep its configuration, it has to be created again to use the negotiated version.
+	client.Close()
+
+	config.Version = version
+	client, err = sarama.NewClient([]string{flBroker}, config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkFlags() error {
+	if flBroker == "" {
+		return errors.New("broker is not set")
+	}
+
+	switch flOutput {
+	case outputTable, outputJSON, outputNDJSON, outputCSV, outputTSV:
+	default:
+		return fmt.Errorf("%q unknown output format", flOutput)
+	}
+
+	if err := parseOutputTemplate(); err != nil {
+		return err
+	}
+
+	switch {
+	case cmd == cmdListGroups, cmd == cmdDescribeGroup, cmd == cmdImportOffsets, cmd == cmdServe, cmd == cmdOverview, cmd == cmdBrokers:
+	case cmd == cmdDescribeTopic && flCluster:
+	default:
+		if flTopic == "" {
+			return errors.New("topic is not set")
+		}
+	}
+
+	switch cmd {
+	case cmdDrift, cmdGetConsumerGroupOffset, cmdDescribeGroup, cmdResetOffsets, cmdExportOffsets, cmdCopyGroup, cmdStatus, cmdCheck:
+		if flConsumerGroup == "" {
+			return errors.New("consumer group is not set")
+		}
+	}
+
+	var err error
+	if partitionSelection, err = koff.ParsePartitions(flPartition); err != nil {
+		return err
+	}
+
+	if cmd == cmdCopyGroup && flTargetGroup == "" {
+		return errors.New("target consumer group is not set")
+	}
+
+	switch flFormat {
+	case "", "json", "csv":
+	default:
+		return fmt.Errorf("%q unknown format", flFormat)
+	}
+
+	if cmd == cmdCheckOffset && flOffset < 0 {
+		return errors.New("offset is not set")
+	}
+
+	if cmd == cmdGetOffsetAt && flTime.IsZero() {
+		return errors.New("time is not set")
+	}
+
+	if flWatch < 0 {
+		return errors.New("watch interval must be positive")
+	}
+
+	if flCluster && flTopic != "" {
+		return errors.New("cluster can't be used with a topic")
+	}
+
+	if flWatch > 0 && flTimeLag {
+		return errors.New("watch can't be used with time")
+	}
+
+	if cmd == cmdOverview && flWorkers <= 0 {
+		return errors.New("workers must be positive")
+	}
+
+	if cmd == cmdServe && flInterval <= 0 {
+		return errors.New("interval must be positive")
+	}
+
+	if cmd == cmdStatus && (flSamples < 2 || flSampleEvery <= 0) {
+		return errors.New("status needs at least 2 samples and a positive interval")
+	}
+
+	return nil
+}
+
+// newContext returns the context of the requests to Kafka, bounded by the timeout if one is set.
+func newContext() (context.Context, context.CancelFunc) {
+	if flTimeout > 0 {
+		return context.WithTimeout(context.Background(), flTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// partitionErrors returns the errors of the partitions which failed, or err itself if the whole call failed.
+func partitionErrors(err error) (map[int32]error, error) {
+	if perr, ok := err.(koff.PartitionErrors); ok {
+		return perr.Errors, nil
+	}
+	return nil, err
+}
+
+// offsetRecords merges the offsets and the errors of the partitions which failed into records.
+func offsetRecords(topic string, offsets map[int32]int64, failed map[int32]error) []offsetRecord {
+	var keys []int
+	for k := range offsets {
+		keys = append(keys, int(k))
+	}
+	for k := range failed {
+		keys = append(keys, int(k))
+	}
+
+	sort.Ints(keys)
+
+	res := []offsetRecord{}
+	for _, part := range keys {
+		rec := offsetRecord{Topic: topic, Partition: int32(part)}
+		if err, ok := failed[int32(part)]; ok {
+			rec.Offset = -1
+			rec.Error = err.Error()
+		} else {
+			rec.Offset = offsets[int32(part)]
+		}
+		res = append(res, rec)
+	}
+
+	return res
+}
+
+func getConsumerGroupOffset() (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	var results resultList
+	for _, group := range groups {
+		res := &offsetsResult{ConsumerGroup: group, Offsets: []offsetRecord{}}
+		for _, t := range targets {
+			offsets, err := k.GetConsumerGroupOffsetsContext(ctx, group, t.topic, flVersion, t.partitions...)
+
+			failed, err := partitionErrors(err)
+			if err != nil {
+				return err
+			}
+
+			for _, rec := range offsetRecords(t.topic, offsets, failed) {
+				rec.ConsumerGroup = group
+				res.Offsets = append(res.Offsets, rec)
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	return render(groupResults(results, flConsumerGroup))
+}
+
+func getOffset(newest bool) (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	res := &offsetsResult{Offsets: []offsetRecord{}}
+	for _, t := range targets {
+		var offsets map[int32]int64
+		if newest {
+			offsets, err = k.GetNewestOffsetsContext(ctx, t.topic, t.partitions...)
+		} else {
+			offsets, err = k.GetOldestOffsetsContext(ctx, t.topic, t.partitions...)
+		}
+
+		failed, err := partitionErrors(err)
+		if err != nil {
+			return err
+		}
+
+		res.Offsets = append(res.Offsets, offsetRecords(t.topic, offsets, failed)...)
+	}
+
+	return render(res)
+}
+
+func getOffsetAt() (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	res := &offsetsResult{Time: &flTime.Time, Offsets: []offsetRecord{}}
+	for _, t := range targets {
+		offsets, err := k.GetOffsetsForTimeContext(ctx, t.topic, flTime.Time, t.partitions...)
+
+		missing, err := partitionErrors(err)
+		if err != nil {
+			return err
+		}
+
+		res.Offsets = append(res.Offsets, offsetRecords(t.topic, offsets, missing)...)
+	}
+
+	return render(res)
+}
+
+func parseResetStrategy() error {
+	var strategies []koff.ResetStrategy
+	fsRO.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "to-earliest":
+			if flToEarliest {
+				strategies = append(strategies, koff.ResetToEarliest())
+			}
+		case "to-latest":
+			if flToLatest {
+				strategies = append(strategies, koff.ResetToLatest())
+			}
+		case "to-offset":
+			strategies = append(strategies, koff.ResetToOffset(flOffset))
+		case "shift-by":
+			strategies = append(strategies, koff.ResetShiftBy(flShiftBy))
+		case "to-datetime":
+			strategies = append(strategies, koff.ResetToTime(flTime.Time))
+		}
+	})
+
+	switch len(strategies) {
+	case 0:
+		return errors.New("reset strategy is not set")
+	case 1:
+		resetStrategy = strategies[0]
+		return nil
+	default:
+		return errors.New("only one reset strategy can be used")
+	}
+}
+
+func resetOffsets() (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	var results resultList
+	for _, group := range groups {
+		res := &resetResult{
+			ConsumerGroup: group,
+			Strategy:      resetStrategy.String(),
+			DryRun:        !flExecute,
+			Partitions:    []resetRecord{},
+		}
+
+		for _, t := range targets {
+			var plan map[int32]koff.OffsetReset
+			if flExecute {
+				plan, err = k.ResetConsumerGroupOffsetsContext(ctx, group, t.topic, flVersion, resetStrategy, flForce, t.partitions...)
+			} else {
+				plan, err = k.PlanOffsetResetContext(ctx, group, t.topic, flVersion, resetStrategy, t.partitions...)
+			}
+			if _, ok := err.(*koff.DescribeGroupError); ok {
+				return fmt.Errorf("%s: %v, use -force to reset anyway", group, err)
+			} else if err == koff.ErrGroupHasActiveMembers {
+				return fmt.Errorf("%s: %v, stop them or use -force", group, err)
+			} else if err != nil {
+				return err
+			}
+
+			var keys []int
+			for k := range plan {
+				keys = append(keys, int(k))
+			}
+
+			sort.Ints(keys)
+
+			for _, part := range keys {
+				o := plan[int32(part)]
+				res.Partitions = append(res.Partitions, resetRecord{ConsumerGroup: group, Topic: t.topic, Partition: int32(part), Before: o.Before, After: o.After, Clamped: o.Clamped})
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	return render(groupResults(results, flConsumerGroup))
+}
+
+// backupFormat returns the format of the backup file, either set explicitly or guessed from its extension.
+func backupFormat() string {
+	switch {
+	case flFormat != "":
+		return flFormat
+	case strings.HasSuffix(strings.ToLower(flFile), ".csv"):
+		return "csv"
+	default:
+		return "json"
+	}
+}
+
+func exportOffsets() (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	group, err := selectGroup(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	backup, err := k.ExportConsumerGroupOffsetsContext(ctx, group, flVersion, targetTopics(targets)...)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if flFile != "-" {
+		w, err = os.Create(flFile)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+
+	if backupFormat() == "csv" {
+		return backup.WriteCSV(w)
+	}
+	return backup.WriteJSON(w)
+}
+
+func importOffsets() (err error) {
+	r := os.Stdin
+	if flFile != "-" {
+		r, err = os.Open(flFile)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+	}
+
+	var backup *koff.OffsetsBackup
+	if backupFormat() == "csv" {
+		backup, err = koff.ReadOffsetsBackupCSV(r)
+	} else {
+		backup, err = koff.ReadOffsetsBackupJSON(r)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read backup. err=%v", err)
+	}
+
+	consumerGroup := flConsumerGroup
+	if consumerGroup == "" {
+		consumerGroup = backup.ConsumerGroup
+	}
+	if consumerGroup == "" {
+		return errors.New("consumer group is not set")
+	}
+
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	var plan map[string]map[int32]koff.OffsetImport
+	if flExecute {
+		plan, err = k.ImportConsumerGroupOffsetsContext(ctx, consumerGroup, flVersion, backup, flForce)
+	} else {
+		plan, err = k.PlanOffsetsImportContext(ctx, consumerGroup, flVersion, backup)
+	}
+	if _, ok := err.(*koff.DescribeGroupError); ok {
+		return fmt.Errorf("%v, use -force to import anyway", err)
+	} else if err == koff.ErrGroupHasActiveMembers {
+		return fmt.Errorf("%v, stop them or use -force", err)
+	} else if plan == nil {
+		return err
+	}
+
+	var topics []string
+	for topic := range plan {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	res := &importResult{
+		ConsumerGroup: consumerGroup,
+		DryRun:        !flExecute,
+		Offsets:       []importRecord{},
+	}
+	for _, topic := range topics {
+		var keys []int
+		for k := range plan[topic] {
+			keys = append(keys, int(k))
+		}
+
+		sort.Ints(keys)
+
+		for _, part := range keys {
+			i := plan[topic][int32(part)]
+			if i.Range.Verdict != koff.InRange {
+				res.Invalid++
+			}
+
+			res.Offsets = append(res.Offsets, importRecord{
+				Topic:     topic,
+				Partition: int32(part),
+				Current:   i.Current,
+				New:       i.New,
+				Oldest:    i.Range.Oldest,
+				Newest:    i.Range.Newest,
+				Verdict:   i.Range.Verdict.String(),
+			})
+		}
+	}
+
+	if rerr := render(res); rerr != nil {
+		return rerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if res.Invalid > 0 {
+		return fmt.Errorf("%d offset(s) are out of range", res.Invalid)
+	}
+
+	return nil
+}
+
+func copyGroup() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	source, err := selectGroup(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	copied, err := k.CopyConsumerGroupOffsetsContext(ctx, source, flTargetGroup, flVersion, flOverwrite, targetTopics(targets)...)
+	if derr, ok := err.(*koff.DescribeGroupError); ok {
+		log.Printf("the offsets were copied without checking that the target has no active members. err=%v", derr)
+		err = nil
+	}
+	switch err {
+	case nil:
+	case koff.ErrGroupHasActiveMembers, koff.ErrGroupHasCommittedOffsets:
+		return fmt.Errorf("target %v, use -overwrite to proceed anyway", err)
+	default:
+		return err
+	}
+
+	var topics []string
+	for topic := range copied {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	res := &copyResult{Source: source, Target: flTargetGroup, Offsets: []copyRecord{}}
+	for _, topic := range topics {
+		var keys []int
+		for k := range copied[topic] {
+			keys = append(keys, int(k))
+		}
+
+		sort.Ints(keys)
+
+		for _, part := range keys {
+			o := copied[topic][int32(part)]
+			res.Offsets = append(res.Offsets, copyRecord{Topic: topic, Partition: int32(part), Offset: o.Offset, Metadata: o.Metadata})
+		}
+	}
+
+	return render(res)
+}
+
+func getDrift() (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	var results resultList
+	for _, group := range groups {
+		res := &driftResult{ConsumerGroup: group, Partitions: []driftRecord{}}
+		for _, t := range targets {
+			report, err := k.GetLagReportContext(ctx, group, t.topic, flVersion, t.partitions...)
+
+			failed, err := partitionErrors(err)
+			if err != nil {
+				return err
+			}
+
+			var keys []int
+			for k := range report {
+				keys = append(keys, int(k))
+			}
+			for k := range failed {
+				keys = append(keys, int(k))
+			}
+
+			sort.Ints(keys)
+
+			for _, part := range keys {
+				if err, ok := failed[int32(part)]; ok {
+					res.Partitions = append(res.Partitions, driftRecord{ConsumerGroup: group, Topic: t.topic, Partition: int32(part), Error: err.Error()})
+					continue
+				}
+				res.Partitions = append(res.Partitions, newDriftRecord(group, t.topic, int32(part), report[int32(part)]))
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	return render(groupResults(results, flConsumerGroup))
+}
+
+func getTimeDrift() (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	var results resultList
+	for _, group := range groups {
+		res := &timeDriftResult{ConsumerGroup: group, Partitions: []timeDriftRecord{}}
+		for _, t := range targets {
+			lags, err := k.GetTimeLagContext(ctx, group, t.topic, flVersion, t.partitions...)
+
+			failed, err := partitionErrors(err)
+			if err != nil {
+				return err
+			}
+
+			var keys []int
+			for k := range lags {
+				keys = append(keys, int(k))
+			}
+			for k := range failed {
+				keys = append(keys, int(k))
+			}
+
+			sort.Ints(keys)
+
+			for _, part := range keys {
+				if err, ok := failed[int32(part)]; ok {
+					res.Partitions = append(res.Partitions, timeDriftRecord{ConsumerGroup: group, Topic: t.topic, Partition: int32(part), Error: err.Error()})
+					continue
+				}
+
+				l := lags[int32(part)]
+
+				res.Partitions = append(res.Partitions, timeDriftRecord{
+					ConsumerGroup:      group,
+					Topic:              t.topic,
+					Partition:          int32(part),
+					Newest:             l.Newest,
+					Committed:          l.Committed,
+					LagSeconds:         l.Lag.Seconds(),
+					NewestTimestamp:    l.NewestTimestamp,
+					CommittedTimestamp: l.CommittedTimestamp,
+				})
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	return render(groupResults(results, flConsumerGroup))
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', 1, 64) + "/s"
+}
+
+func formatETA(eta time.Duration) string {
+	switch {
+	case eta < 0:
+		return "never"
+	case eta == 0:
+		return "caught up"
+	default:
+		return (eta / time.Second * time.Second).String()
+	}
+}
+
+// sampleOffsets gets the newest offsets of the target and the offsets committed on it by the consumer group.
+//
+// The timeout applies to each sample instead of the whole command. Partitions which failed are returned in failed
+// and left out of the offsets.
+func sampleOffsets(k *koff.Koff, group string, t target) (newest, committed map[int32]int64, failed map[int32]error, err error) {
+	ctx, cancel := newContext()
+	defer cancel()
+
+	newest, err = k.GetNewestOffsetsContext(ctx, t.topic, t.partitions...)
+	failedNewest, err := partitionErrors(err)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	committed, err = k.GetConsumerGroupOffsetsContext(ctx, group, t.topic, flVersion, t.partitions...)
+	failed, err = partitionErrors(err)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if failed == nil {
+		failed = make(map[int32]error)
+	}
+	for p, err := range failedNewest {
+		if _, ok := failed[p]; !ok {
+			failed[p] = err
+		}
+	}
+	for p := range failed {
+		delete(committed, p)
+	}
+
+	return newest, committed, failed, nil
+}
+
+func watchDrift() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	// The trackers are keyed by topic and partition, each group needs its own.
+	trackers := make(map[string]*koff.LagTracker)
+	for _, group := range groups {
+		trackers[group] = koff.NewLagTracker()
+	}
+
+	r := newRenderer()
+
+	ticker := time.NewTicker(flWatch)
+	defer ticker.Stop()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	for {
+		now := time.Now()
+
+		for _, group := range groups {
+			res := &watchResult{ConsumerGroup: group, Time: now, Partitions: []watchRecord{}}
+			for _, t := range targets {
+				availableOffsets, offsets, failed, err := sampleOffsets(k, group, t)
+				if err != nil {
+					return err
+				}
+
+				rates := trackers[group].Track(t.topic, now, availableOffsets, offsets)
+
+				var keys []int
+				for k := range offsets {
+					keys = append(keys, int(k))
+				}
+				for k := range failed {
+					keys = append(keys, int(k))
+				}
+
+				sort.Ints(keys)
+
+				for _, key := range keys {
+					part := int32(key)
+					if err, ok := failed[part]; ok {
+						res.Partitions = append(res.Partitions, watchRecord{Time: now, ConsumerGroup: group, Topic: t.topic, Partition: part, Error: err.Error()})
+						continue
+					}
+
+					o := offsets[part]
+					v := availableOffsets[part]
+
+					rec := watchRecord{Time: now, ConsumerGroup: group, Topic: t.topic, Partition: part, Newest: v, Committed: o}
+					if lag, ok := koff.Lag(v, o); ok {
+						rec.Drift = lag
+					} else {
+						rec.Uncommitted = true
+					}
+					if rt, ok := rates[part]; ok {
+						rec.HasRates = true
+						rec.ProduceRate = rt.ProduceRate
+						rec.ConsumeRate = rt.ConsumeRate
+						rec.Trend = rt.Trend.String()
+						rec.ETASeconds = rt.ETA.Seconds()
+					}
+					res.Partitions = append(res.Partitions, rec)
+				}
+			}
+
+			if err := r.render(res); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func status() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	// The evaluators are keyed by topic and partition, each group needs its own.
+	evaluators := make(map[string]*koff.LagEvaluator)
+	for _, group := range groups {
+		evaluators[group] = koff.NewLagEvaluator(flSamples)
+	}
+
+	// failures maps groups and topics to the partitions which failed at the last sample.
+	failures := make(map[string]map[string]map[int32]error)
+	for _, group := range groups {
+		failures[group] = make(map[string]map[int32]error)
+	}
+
+	for i := 0; i < flSamples; i++ {
+		if i > 0 {
+			time.Sleep(flSampleEvery)
+		}
+
+		now := time.Now()
+
+		for _, group := range groups {
+			for _, t := range targets {
+				availableOffsets, offsets, failed, err := sampleOffsets(k, group, t)
+				if err != nil {
+					return err
+				}
+
+				evaluators[group].Track(t.topic, now, availableOffsets, offsets)
+				failures[group][t.topic] = failed
+			}
+		}
+	}
+
+	// The samples can outlast the timeout, the oldest offsets get their own.
+	ctx, cancel = newContext()
+	defer cancel()
+
+	// The partitions whose oldest offset failed are still evaluated, without the retention check.
+	oldestOffsets := make(map[string]map[int32]int64)
+	oldestFailures := make(map[string]map[int32]error)
+	for _, t := range targets {
+		offsets, err := k.GetOldestOffsetsContext(ctx, t.topic, t.partitions...)
+
+		failed, err := partitionErrors(err)
+		if err != nil {
+			return err
+		}
+		oldestOffsets[t.topic] = offsets
+		oldestFailures[t.topic] = failed
+	}
+
+	var results resultList
+	for _, group := range groups {
+		for _, t := range targets {
+			st := evaluators[group].Evaluate(t.topic, oldestOffsets[t.topic])
+
+			failed := failures[group][t.topic]
+
+			var keys []int
+			for k := range st.Partitions {
+				keys = append(keys, int(k))
+			}
+			for k := range failed {
+				if _, ok := st.Partitions[k]; !ok {
+					keys = append(keys, int(k))
+				}
+			}
+
+			sort.Ints(keys)
+
+			res := &statusResult{
+				ConsumerGroup: group,
+				Topic:         t.topic,
+				Status:        st.Status.String(),
+				Partitions:    []statusRecord{},
+			}
+			for _, part := range keys {
+				if err, ok := failed[int32(part)]; ok {
+					res.Partitions = append(res.Partitions, statusRecord{ConsumerGroup: group, Topic: t.topic, Partition: int32(part), Error: err.Error()})
+					continue
+				}
+
+				s := st.Partitions[int32(part)]
+
+				rec := statusRecord{
+					ConsumerGroup: group,
+					Topic:         t.topic,
+					Partition:     int32(part),
+					Lag:           s.Lag,
+					Status:        s.Status.String(),
+					Reason:        s.Reason,
+					Samples:       s.Samples,
+				}
+				if err, ok := oldestFailures[t.topic][int32(part)]; ok {
+					rec.OldestError = err.Error()
+				}
+				res.Partitions = append(res.Partitions, rec)
+			}
+
+			results = append(results, res)
+		}
+	}
+
+	return render(groupResults(results, flConsumerGroup, flTopic))
+}
+
+func checkOffset() (err error) {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
 
-	client, err = sarama.NewClient([]string{flBroker}, config)
+	targets, err := selectTargets(ctx, k)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	res := &checkOffsetResult{Offset: flOffset, Partitions: []offsetRangeRecord{}}
+	for _, t := range targets {
+		ranges, err := k.CheckOffsetRangeContext(ctx, t.topic, flOffset, t.partitions...)
+		if err != nil {
+			return err
+		}
 
-func checkFlags() error {
-	if flBroker == "" {
-		return errors.New("broker is not set")
+		var keys []int
+		for k := range ranges {
+			keys = append(keys, int(k))
+		}
+
+		sort.Ints(keys)
+
+		for _, part := range keys {
+			r := ranges[int32(part)]
+			if r.Verdict != koff.InRange {
+				res.OutOfRange++
+			}
+
+			res.Partitions = append(res.Partitions, offsetRangeRecord{
+				Topic:     t.topic,
+				Partition: int32(part),
+				Offset:    r.Offset,
+				Oldest:    r.Oldest,
+				Newest:    r.Newest,
+				Verdict:   r.Verdict.String(),
+			})
+		}
 	}
 
-	if flTopic == "" {
-		return errors.New("topic is not set")
+	if err := render(res); err != nil {
+		return err
 	}
 
-	if cmd == cmdDrift || cmd == cmdGetConsumerGroupOffset {
-		if flConsumerGroup == "" {
-			return errors.New("consumer group is not set")
-		}
+	if res.OutOfRange > 0 {
+		return fmt.Errorf("offset %d is out of range for %d partition(s)", flOffset, res.OutOfRange)
 	}
 
 	return nil
 }
 
-func getConsumerGroupOffset() (err error) {
+func listGroups() error {
 	k := koff.New(client)
-	if err := k.Init(); err != nil {
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	groups, err := k.ListConsumerGroupsContext(ctx)
+	failed, ok := err.(koff.BrokerErrors)
+	if err != nil && (!ok || len(groups) == 0) {
 		return err
 	}
 
-	var offsets map[int32]int64
-	{
-		partition := int32(flPartition)
-		if partition > -1 {
-			offsets, err = k.GetConsumerGroupOffsets(flConsumerGroup, flTopic, flVersion, partition)
-		} else {
-			offsets, err = k.GetConsumerGroupOffsets(flConsumerGroup, flTopic, flVersion)
+	var keys []string
+	for k := range groups {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	res := &listGroupsResult{Groups: []groupRecord{}, Brokers: []brokerErrorRecord{}}
+	for _, group := range keys {
+		res.Groups = append(res.Groups, groupRecord{Group: group, ProtocolType: groups[group]})
+	}
+	for _, id := range sortedBrokers(failed) {
+		res.Brokers = append(res.Brokers, brokerErrorRecord{ID: id, Error: failed[id].Error()})
+	}
+
+	return render(res)
+}
+
+func formatAssignment(assignment map[string][]int32) string {
+	var topics []string
+	for topic := range assignment {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	var parts []string
+	for _, topic := range topics {
+		var partitions []string
+		for _, p := range assignment[topic] {
+			partitions = append(partitions, strconv.Itoa(int(p)))
 		}
+		parts = append(parts, topic+":"+strings.Join(partitions, ","))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func describeGroup() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return err
+	}
 
+	var results resultList
+	for _, group := range groups {
+		desc, err := k.DescribeConsumerGroupContext(ctx, group)
 		if err != nil {
 			return err
 		}
+
+		res := &describeGroupResult{
+			Group:        desc.Group,
+			State:        desc.State,
+			ProtocolType: desc.ProtocolType,
+			Protocol:     desc.Protocol,
+			Members:      []memberRecord{},
+		}
+		for _, m := range desc.Members {
+			res.Members = append(res.Members, memberRecord{
+				Group:      desc.Group,
+				MemberID:   m.MemberID,
+				ClientID:   m.ClientID,
+				ClientHost: m.ClientHost,
+				Assignment: formatAssignment(m.Assignment),
+			})
+		}
+
+		results = append(results, res)
 	}
 
-	var keys []int
-	for k, _ := range offsets {
-		keys = append(keys, int(k))
+	return render(groupResults(results, flConsumerGroup))
+}
+
+func gcgoCommand() error {
+	if err := fsGCGO.Parse(flag.Args()[1:]); err != nil {
+		return err
 	}
 
-	sort.Ints(keys)
+	if err := checkFlags(); err != nil {
+		return err
+	}
 
-	fmt.Printf("%-12s %-10s\n", "partition", "offset")
-	for _, part := range keys {
-		fmt.Printf("p:%-10d %-10d\n", part, offsets[int32(part)])
+	if err := initSarama(); err != nil {
+		return err
 	}
+	defer client.Close()
 
-	return nil
+	return getConsumerGroupOffset()
 }
 
-func getOffset(newest bool) (err error) {
-	k := koff.New(client)
-	if err := k.Init(); err != nil {
+func getOffsetCommand(newest bool) error {
+	if err := fsGO.Parse(flag.Args()[1:]); err != nil {
 		return err
 	}
 
-	var offsets map[int32]int64
-	{
-		partition := int32(flPartition)
-		if partition > -1 && newest {
-			offsets, err = k.GetNewestOffsets(flTopic, partition)
-		} else if partition > -1 && !newest {
-			offsets, err = k.GetOldestOffsets(flTopic, partition)
-		} else if partition == -1 && newest {
-			offsets, err = k.GetNewestOffsets(flTopic)
-		} else if partition == -1 && !newest {
-			offsets, err = k.GetOldestOffsets(flTopic)
-		}
+	if err := checkFlags(); err != nil {
+		return err
+	}
 
-		if err != nil {
-			return err
-		}
+	if err := initSarama(); err != nil {
+		return err
 	}
+	defer client.Close()
 
-	var keys []int
-	for k, _ := range offsets {
-		keys = append(keys, int(k))
+	return getOffset(newest)
+}
+
+func driftCommand() error {
+	if err := fsDrift.Parse(flag.Args()[1:]); err != nil {
+		return err
 	}
 
-	sort.Ints(keys)
+	if err := checkFlags(); err != nil {
+		return err
+	}
 
-	fmt.Printf("%-12s %-10s\n", "partition", "offset")
-	for _, part := range keys {
-		fmt.Printf("p:%-10d %-10d\n", part, offsets[int32(part)])
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if flTimeLag {
+		return getTimeDrift()
 	}
 
-	return nil
+	if flWatch > 0 {
+		return watchDrift()
+	}
+
+	return getDrift()
 }
 
-func getDrift() (err error) {
-	k := koff.New(client)
-	if err := k.Init(); err != nil {
+func checkOffsetCommand() error {
+	if err := fsCO.Parse(flag.Args()[1:]); err != nil {
 		return err
 	}
 
-	partition := int32(flPartition)
+	if err := checkFlags(); err != nil {
+		return err
+	}
 
-	var availableOffsets map[int32]int64
-	{
-		if partition > -1 {
-			availableOffsets, err = k.GetNewestOffsets(flTopic, partition)
-		} else {
-			availableOffsets, err = k.GetNewestOffsets(flTopic)
-		}
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
 
-		if err != nil {
-			return err
-		}
+	return checkOffset()
+}
+
+func listGroupsCommand() error {
+	if err := checkFlags(); err != nil {
+		return err
 	}
 
-	var offsets map[int32]int64
-	{
-		if partition > -1 {
-			offsets, err = k.GetConsumerGroupOffsets(flConsumerGroup, flTopic, flVersion, partition)
-		} else {
-			offsets, err = k.GetConsumerGroupOffsets(flConsumerGroup, flTopic, flVersion)
-		}
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
 
-		if err != nil {
-			return err
-		}
+	return listGroups()
+}
+
+func describeGroupCommand() error {
+	if err := fsDG.Parse(flag.Args()[1:]); err != nil {
+		return err
 	}
 
-	var keys []int
-	for k, _ := range availableOffsets {
-		keys = append(keys, int(k))
+	if err := checkFlags(); err != nil {
+		return err
 	}
 
-	sort.Ints(keys)
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
 
-	fmt.Printf("%-12s %-10s %-10s -> %s\n", "partition", "newest", "offset", "drift")
-	for _, part := range keys {
-		o := offsets[int32(part)]
-		v := availableOffsets[int32(part)]
+	return describeGroup()
+}
 
-		fmt.Printf("p:%-10d %-10d %-10d -> %d", part, v, o, v-o)
-		if o != v {
-			fmt.Printf("   !!!!\n")
-		} else {
-			fmt.Printf("\n")
-		}
+func getOffsetAtCommand() error {
+	if err := fsGOA.Parse(flag.Args()[1:]); err != nil {
+		return err
 	}
 
-	return nil
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return getOffsetAt()
 }
 
-func gcgoCommand() error {
-	if err := fsGCGO.Parse(flag.Args()[1:]); err != nil {
+func resetOffsetsCommand() error {
+	if err := fsRO.Parse(flag.Args()[1:]); err != nil {
 		return err
 	}
 
@@ -198,16 +1365,20 @@ func gcgoCommand() error {
 		return err
 	}
 
+	if err := parseResetStrategy(); err != nil {
+		return err
+	}
+
 	if err := initSarama(); err != nil {
 		return err
 	}
 	defer client.Close()
 
-	return getConsumerGroupOffset()
+	return resetOffsets()
 }
 
-func getOffsetCommand(newest bool) error {
-	if err := fsGO.Parse(flag.Args()[1:]); err != nil {
+func exportOffsetsCommand() error {
+	if err := fsEO.Parse(flag.Args()[1:]); err != nil {
 		return err
 	}
 
@@ -220,11 +1391,11 @@ func getOffsetCommand(newest bool) error {
 	}
 	defer client.Close()
 
-	return getOffset(newest)
+	return exportOffsets()
 }
 
-func driftCommand() error {
-	if err := fsDrift.Parse(flag.Args()[1:]); err != nil {
+func importOffsetsCommand() error {
+	if err := fsIO.Parse(flag.Args()[1:]); err != nil {
 		return err
 	}
 
@@ -237,7 +1408,58 @@ func driftCommand() error {
 	}
 	defer client.Close()
 
-	return getDrift()
+	return importOffsets()
+}
+
+func copyGroupCommand() error {
+	if err := fsCG.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return copyGroup()
+}
+
+func serveCommand() error {
+	if err := fsServe.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return serve()
+}
+
+func statusCommand() error {
+	if err := fsSt.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return status()
 }
 
 func main() {
@@ -268,6 +1490,93 @@ func main() {
 			log.Fatalln(err)
 			return
 		}
+	case "check-offset", "co":
+		cmd = cmdCheckOffset
+		if err := checkOffsetCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "list-groups", "lg":
+		cmd = cmdListGroups
+		if err := listGroupsCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "describe-group", "dg":
+		cmd = cmdDescribeGroup
+		if err := describeGroupCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "get-offset-at", "goa":
+		cmd = cmdGetOffsetAt
+		if err := getOffsetAtCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "reset-offsets", "ro":
+		cmd = cmdResetOffsets
+		if err := resetOffsetsCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "export-offsets", "eo":
+		cmd = cmdExportOffsets
+		if err := exportOffsetsCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "import-offsets", "io":
+		cmd = cmdImportOffsets
+		if err := importOffsetsCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "copy-group", "cg":
+		cmd = cmdCopyGroup
+		if err := copyGroupCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "serve":
+		cmd = cmdServe
+		if err := serveCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "status", "st":
+		cmd = cmdStatus
+		if err := statusCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "check":
+		cmd = cmdCheck
+		os.Exit(checkCommand())
+	case "overview", "ov":
+		cmd = cmdOverview
+		if err := overviewCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "topic-stats", "ts":
+		cmd = cmdTopicStats
+		if err := topicStatsCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "describe-topic", "dt":
+		cmd = cmdDescribeTopic
+		if err := describeTopicCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
+	case "brokers":
+		cmd = cmdBrokers
+		if err := brokersCommand(); err != nil {
+			log.Fatalln(err)
+			return
+		}
 	}
 
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/koff"
+)
+
+// The output formats.
+const (
+	outputTable  = "table"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+	outputCSV    = "csv"
+	outputTSV    = "tsv"
+)
+
+// result is the typed result of a command.
+//
+// In JSON the whole result is encoded; the NDJSON, CSV and TSV outputs only contain its records.
+type result interface {
+	// printTable prints the result as a human readable table.
+	printTable(w io.Writer)
+	// records returns the rows of the result. They must be flat structs with json tags.
+	records() []interface{}
+}
+
+// resultList is the result of a command run on several consumer groups. In JSON it is encoded as an array of the results.
+type resultList []result
+
+func (l resultList) printTable(w io.Writer) {
+	for i, res := range l {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		res.printTable(w)
+	}
+}
+
+func (l resultList) records() []interface{} {
+	var res []interface{}
+	for _, r := range l {
+		res = append(res, r.records()...)
+	}
+	return res
+}
+
+// groupResults returns the results of a command as a single result. It is the only result if each of the selectors can only
+// select a single name, so the output of a command run on one consumer group doesn't depend on the selection, and a resultList otherwise.
+func groupResults(results resultList, selectors ...string) result {
+	for _, s := range selectors {
+		sel, err := koff.ParseSelector(s)
+		if err != nil || !sel.IsLiteral() || len(sel.Names()) > 1 {
+			return results
+		}
+	}
+
+	if len(results) != 1 {
+		return results
+	}
+
+	return results[0]
+}
+
+// renderer writes results in the output format.
+//
+// The CSV and TSV header is only written once and tables are separated by a blank line, so the same renderer can be used for successive results.
+type renderer struct {
+	w           io.Writer
+	format      string
+	wroteHeader bool
+	wroteTable  bool
+}
+
+func newRenderer() *renderer {
+	return &renderer{w: os.Stdout, format: flOutput}
+}
+
+// render writes the result to stdout in the output format.
+func render(res result) error {
+	return newRenderer().render(res)
+}
+
+func (r *renderer) render(res result) error {
+	if outputTemplate != nil {
+		return outputTemplate.Execute(r.w, res)
+	}
+
+	switch r.format {
+	case outputJSON:
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(r.w, "%s\n", data)
+		return err
+
+	case outputNDJSON:
+		enc := json.NewEncoder(r.w)
+		for _, rec := range res.records() {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case outputCSV, outputTSV:
+		w := csv.NewWriter(r.w)
+		if r.format == outputTSV {
+			w.Comma = '\t'
+		}
+
+		records := res.records()
+		if !r.wroteHeader && len(records) > 0 {
+			if err := w.Write(recordColumns(records[0])); err != nil {
+				return err
+			}
+			r.wroteHeader = true
+		}
+		for _, rec := range records {
+			if err := w.Write(recordValues(rec)); err != nil {
+				return err
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+
+	default:
+		if r.wroteTable {
+			fmt.Fprintln(r.w)
+		}
+		res.printTable(r.w)
+		r.wroteTable = true
+		return nil
+	}
+}
+
+// recordColumns returns the names of the fields of the record, as given by their json tag.
+func recordColumns(rec interface{}) []string {
+	typ := reflect.TypeOf(rec)
+
+	var res []string
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		res = append(res, name)
+	}
+
+	return res
+}
+
+// recordValues returns the values of the fields of the record, in the same order as recordColumns.
+func recordValues(rec interface{}) []string {
+	typ := reflect.TypeOf(rec)
+	val := reflect.ValueOf(rec)
+
+	var res []string
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		switch v := val.Field(i).Interface().(type) {
+		case time.Time:
+			if v.IsZero() {
+				res = append(res, "")
+			} else {
+				res = append(res, v.Format(time.RFC3339Nano))
+			}
+		case float64:
+			res = append(res, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			res = append(res, fmt.Sprint(v))
+		}
+	}
+
+	return res
+}
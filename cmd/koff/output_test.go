@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupResults(t *testing.T) {
+	results := resultList{
+		&offsetsResult{ConsumerGroup: "a", Offsets: []offsetRecord{{ConsumerGroup: "a", Topic: "foobar", Partition: 0, Offset: 10}}},
+		&offsetsResult{ConsumerGroup: "b", Offsets: []offsetRecord{{ConsumerGroup: "b", Topic: "foobar", Partition: 0, Offset: 20}}},
+	}
+
+	require.Equal(t, results[0], groupResults(results[:1], "a"))
+	require.Equal(t, results[:1], groupResults(results[:1], "a*"))
+	require.Equal(t, results[:1], groupResults(results[:1], "a,b"))
+	require.Equal(t, results[:1], groupResults(results[:1], "a", "foo*"))
+	require.Equal(t, results, groupResults(results, "a,b"))
+}
+
+func TestRenderResultList(t *testing.T) {
+	results := resultList{
+		&offsetsResult{ConsumerGroup: "a", Offsets: []offsetRecord{{ConsumerGroup: "a", Topic: "foobar", Partition: 0, Offset: 10}}},
+		&offsetsResult{ConsumerGroup: "b", Offsets: []offsetRecord{{ConsumerGroup: "b", Topic: "foobar", Partition: 0, Offset: 20}}},
+	}
+
+	var buf bytes.Buffer
+	err := (&renderer{w: &buf, format: outputJSON}).render(results)
+	require.Nil(t, err)
+
+	var decoded []offsetsResult
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, 2, len(decoded))
+	require.Equal(t, "b", decoded[1].ConsumerGroup)
+
+	buf.Reset()
+	err = (&renderer{w: &buf, format: outputCSV}).render(results)
+	require.Nil(t, err)
+	require.Equal(t, 3, bytes.Count(buf.Bytes(), []byte("\n")))
+	require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("consumer_group")))
+}
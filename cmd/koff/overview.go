@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"sort"
+
+	"github.com/vrischmann/koff"
+)
+
+// overview reports the lag of every consumer group on every topic it consumes.
+func overview() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	var (
+		matrix koff.LagMatrix
+		groups []string
+		err    error
+	)
+	if flConsumerGroup != "" {
+		groups, err = selectGroups(ctx, k)
+		if err != nil {
+			return err
+		}
+
+		matrix, err = k.GetGroupsLagMatrixContext(ctx, flVersion, flWorkers, groups...)
+	} else {
+		matrix, err = k.GetLagMatrixContext(ctx, flVersion, flWorkers)
+	}
+
+	var (
+		failed        koff.GroupErrors
+		failedBrokers koff.BrokerErrors
+	)
+	switch e := err.(type) {
+	case nil:
+	case koff.GroupErrors:
+		failed = e
+	case koff.LagMatrixErrors:
+		failed, failedBrokers = e.Groups, e.Brokers
+	default:
+		return err
+	}
+
+	res := &overviewResult{Rows: []overviewRecord{}, Brokers: []brokerErrorRecord{}}
+	for _, l := range matrix {
+		res.Rows = append(res.Rows, overviewRecord{
+			ConsumerGroup:     l.ConsumerGroup,
+			Topic:             l.Topic,
+			TotalLag:          l.TotalLag,
+			MaxLag:            l.MaxLag,
+			LaggingPartitions: l.LaggingPartitions,
+			Partitions:        l.Partitions,
+		})
+		if l.LaggingPartitions > 0 {
+			res.Lagging++
+		}
+	}
+	for _, group := range sortedGroups(failed) {
+		res.Rows = append(res.Rows, overviewRecord{ConsumerGroup: group, Error: failed[group].Error()})
+	}
+	for _, id := range sortedBrokers(failedBrokers) {
+		res.Brokers = append(res.Brokers, brokerErrorRecord{ID: id, Error: failedBrokers[id].Error()})
+	}
+
+	return render(res)
+}
+
+func sortedGroups(errs koff.GroupErrors) []string {
+	var groups []string
+	for group := range errs {
+		groups = append(groups, group)
+	}
+
+	sort.Strings(groups)
+
+	return groups
+}
+
+func sortedBrokers(errs koff.BrokerErrors) []int32 {
+	var keys []int
+	for id := range errs {
+		keys = append(keys, int(id))
+	}
+
+	sort.Ints(keys)
+
+	res := make([]int32, len(keys))
+	for i, id := range keys {
+		res[i] = int32(id)
+	}
+
+	return res
+}
+
+func overviewCommand() error {
+	if err := fsOv.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return overview()
+}
@@ -0,0 +1,814 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/koff"
+)
+
+// eachTopic calls fn with the bounds of each run of rows of the same topic, the rows of a result being grouped by topic.
+func eachTopic(n int, topicOf func(i int) string, fn func(topic string, from, to int)) {
+	for from := 0; from < n; {
+		to := from + 1
+		for to < n && topicOf(to) == topicOf(from) {
+			to++
+		}
+
+		fn(topicOf(from), from, to)
+		from = to
+	}
+}
+
+// printTopicHeader prints the name of the topic before its rows, separated from the rows of the previous topic.
+func printTopicHeader(w io.Writer, topic string, first bool) {
+	if !first {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "topic: %s\n", topic)
+}
+
+type offsetRecord struct {
+	ConsumerGroup string `json:"consumer_group,omitempty"`
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Offset        int64  `json:"offset"`
+	Error         string `json:"error,omitempty"`
+}
+
+// offsetsResult is the result of get-offset, get-consumer-group-offset and get-offset-at.
+type offsetsResult struct {
+	ConsumerGroup string         `json:"consumer_group,omitempty"`
+	Time          *time.Time     `json:"time,omitempty"`
+	Offsets       []offsetRecord `json:"offsets"`
+}
+
+func (r *offsetsResult) printTable(w io.Writer) {
+	if r.Time != nil {
+		fmt.Fprintf(w, "offsets at %s\n\n", r.Time.Format(time.RFC3339))
+	}
+	if r.ConsumerGroup != "" {
+		fmt.Fprintf(w, "consumer group: %s\n\n", r.ConsumerGroup)
+	}
+
+	eachTopic(len(r.Offsets), func(i int) string { return r.Offsets[i].Topic }, func(topic string, from, to int) {
+		printTopicHeader(w, topic, from == 0)
+
+		fmt.Fprintf(w, "%-12s %-10s\n", "partition", "offset")
+		for _, o := range r.Offsets[from:to] {
+			if o.Error != "" {
+				fmt.Fprintf(w, "p:%-10d error: %s   !!!!\n", o.Partition, o.Error)
+				continue
+			}
+			fmt.Fprintf(w, "p:%-10d %-10d\n", o.Partition, o.Offset)
+		}
+	})
+}
+
+func (r *offsetsResult) records() []interface{} {
+	var res []interface{}
+	for _, o := range r.Offsets {
+		res = append(res, o)
+	}
+	return res
+}
+
+type driftRecord struct {
+	ConsumerGroup string `json:"consumer_group"`
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Newest        int64  `json:"newest"`
+	Committed     int64  `json:"committed"`
+	Drift         int64  `json:"drift"`
+	Assigned      bool   `json:"assigned"`
+	OwnerUnknown  bool   `json:"owner_unknown"`
+	MemberID      string `json:"member_id"`
+	ClientID      string `json:"client_id"`
+	ClientHost    string `json:"client_host"`
+	Error         string `json:"error,omitempty"`
+}
+
+func newDriftRecord(group, topic string, partition int32, l koff.PartitionLag) driftRecord {
+	res := driftRecord{
+		ConsumerGroup: group,
+		Topic:         topic,
+		Partition:     partition,
+		Newest:        l.Newest,
+		Committed:     l.Committed,
+		Drift:         l.Drift,
+		OwnerUnknown:  l.OwnerUnknown,
+	}
+	if l.Owner != nil {
+		res.Assigned = true
+		res.MemberID = l.Owner.MemberID
+		res.ClientID = l.Owner.ClientID
+		res.ClientHost = strings.TrimPrefix(l.Owner.ClientHost, "/")
+	}
+
+	return res
+}
+
+func (r driftRecord) owner() string {
+	if r.OwnerUnknown {
+		return "unknown"
+	}
+	if !r.Assigned {
+		return "unassigned"
+	}
+	return r.ClientID + "@" + r.ClientHost + " (" + r.MemberID + ")"
+}
+
+// driftResult is the result of drift.
+type driftResult struct {
+	ConsumerGroup string        `json:"consumer_group"`
+	Partitions    []driftRecord `json:"partitions"`
+}
+
+func (r *driftResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "consumer group: %s\n\n", r.ConsumerGroup)
+
+	eachTopic(len(r.Partitions), func(i int) string { return r.Partitions[i].Topic }, func(topic string, from, to int) {
+		printTopicHeader(w, topic, from == 0)
+
+		var total int64
+
+		fmt.Fprintf(w, "%-12s %-10s %-10s -> %-10s %s\n", "partition", "newest", "offset", "drift", "owner")
+		for _, l := range r.Partitions[from:to] {
+			if l.Error != "" {
+				fmt.Fprintf(w, "p:%-10d error: %s   !!!!\n", l.Partition, l.Error)
+				continue
+			}
+
+			total += l.Drift
+
+			fmt.Fprintf(w, "p:%-10d %-10d %-10d -> %-10d %s", l.Partition, l.Newest, l.Committed, l.Drift, l.owner())
+			if l.Drift != 0 || !l.Assigned {
+				fmt.Fprintf(w, "   !!!!\n")
+			} else {
+				fmt.Fprintf(w, "\n")
+			}
+		}
+
+		fmt.Fprintf(w, "%-12s %-10s %-10s -> %d\n", "total", "", "", total)
+	})
+}
+
+func (r *driftResult) records() []interface{} {
+	var res []interface{}
+	for _, l := range r.Partitions {
+		res = append(res, l)
+	}
+	return res
+}
+
+type timeDriftRecord struct {
+	ConsumerGroup      string    `json:"consumer_group"`
+	Topic              string    `json:"topic"`
+	Partition          int32     `json:"partition"`
+	Newest             int64     `json:"newest"`
+	Committed          int64     `json:"committed"`
+	LagSeconds         float64   `json:"lag_seconds"`
+	NewestTimestamp    time.Time `json:"newest_timestamp"`
+	CommittedTimestamp time.Time `json:"committed_timestamp"`
+	Error              string    `json:"error,omitempty"`
+}
+
+// timeDriftResult is the result of drift -time.
+type timeDriftResult struct {
+	ConsumerGroup string            `json:"consumer_group"`
+	Partitions    []timeDriftRecord `json:"partitions"`
+}
+
+func (r *timeDriftResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "consumer group: %s\n\n", r.ConsumerGroup)
+
+	eachTopic(len(r.Partitions), func(i int) string { return r.Partitions[i].Topic }, func(topic string, from, to int) {
+		printTopicHeader(w, topic, from == 0)
+
+		var max time.Duration
+
+		fmt.Fprintf(w, "%-12s %-10s %-10s -> %-12s %s\n", "partition", "newest", "offset", "drift", "newest timestamp")
+		for _, l := range r.Partitions[from:to] {
+			if l.Error != "" {
+				fmt.Fprintf(w, "p:%-10d error: %s   !!!!\n", l.Partition, l.Error)
+				continue
+			}
+
+			lag := time.Duration(l.LagSeconds * float64(time.Second))
+			if lag > max {
+				max = lag
+			}
+
+			fmt.Fprintf(w, "p:%-10d %-10d %-10d -> %-12s %s", l.Partition, l.Newest, l.Committed, lag, l.NewestTimestamp.Format(time.RFC3339))
+			if lag > 0 {
+				fmt.Fprintf(w, "   !!!!\n")
+			} else {
+				fmt.Fprintf(w, "\n")
+			}
+		}
+
+		fmt.Fprintf(w, "%-12s %-10s %-10s -> %s\n", "max", "", "", max)
+	})
+}
+
+func (r *timeDriftResult) records() []interface{} {
+	var res []interface{}
+	for _, l := range r.Partitions {
+		res = append(res, l)
+	}
+	return res
+}
+
+type watchRecord struct {
+	Time          time.Time `json:"time"`
+	ConsumerGroup string    `json:"consumer_group"`
+	Topic         string    `json:"topic"`
+	Partition     int32     `json:"partition"`
+	Newest        int64     `json:"newest"`
+	Committed     int64     `json:"committed"`
+	Drift         int64     `json:"drift"`
+	Uncommitted   bool      `json:"uncommitted,omitempty"`
+	HasRates      bool      `json:"has_rates"`
+	ProduceRate   float64   `json:"produce_rate"`
+	ConsumeRate   float64   `json:"consume_rate"`
+	Trend         string    `json:"trend"`
+	ETASeconds    float64   `json:"eta_seconds"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// watchResult is a single sample of drift -watch.
+type watchResult struct {
+	ConsumerGroup string        `json:"consumer_group"`
+	Time          time.Time     `json:"time"`
+	Partitions    []watchRecord `json:"partitions"`
+}
+
+func (r *watchResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%s %s\n", r.Time.Format(time.RFC3339), r.ConsumerGroup)
+
+	eachTopic(len(r.Partitions), func(i int) string { return r.Partitions[i].Topic }, func(topic string, from, to int) {
+		printTopicHeader(w, topic, from == 0)
+
+		var total int64
+
+		fmt.Fprintf(w, "%-12s %-10s %-10s -> %-10s %-12s %-12s %-10s %s\n", "partition", "newest", "offset", "drift", "produce", "consume", "trend", "eta")
+		for _, p := range r.Partitions[from:to] {
+			if p.Error != "" {
+				fmt.Fprintf(w, "p:%-10d error: %s   !!!!\n", p.Partition, p.Error)
+				continue
+			}
+			if p.Uncommitted {
+				fmt.Fprintf(w, "p:%-10d %-10d no committed offset\n", p.Partition, p.Newest)
+				continue
+			}
+
+			total += p.Drift
+
+			fmt.Fprintf(w, "p:%-10d %-10d %-10d -> %-10d", p.Partition, p.Newest, p.Committed, p.Drift)
+			if p.HasRates {
+				eta := time.Duration(p.ETASeconds * float64(time.Second))
+				fmt.Fprintf(w, " %-12s %-12s %-10s %s\n", formatRate(p.ProduceRate), formatRate(p.ConsumeRate), p.Trend, formatETA(eta))
+			} else {
+				fmt.Fprintf(w, " %-12s %-12s %-10s %s\n", "-", "-", "-", "-")
+			}
+		}
+
+		fmt.Fprintf(w, "%-12s %-10s %-10s -> %d\n", "total", "", "", total)
+	})
+}
+
+func (r *watchResult) records() []interface{} {
+	var res []interface{}
+	for _, p := range r.Partitions {
+		res = append(res, p)
+	}
+	return res
+}
+
+type offsetRangeRecord struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Oldest    int64  `json:"oldest"`
+	Newest    int64  `json:"newest"`
+	Verdict   string `json:"verdict"`
+}
+
+// checkOffsetResult is the result of check-offset.
+type checkOffsetResult struct {
+	Offset     int64               `json:"offset"`
+	OutOfRange int                 `json:"out_of_range"`
+	Partitions []offsetRangeRecord `json:"partitions"`
+}
+
+func (r *checkOffsetResult) printTable(w io.Writer) {
+	eachTopic(len(r.Partitions), func(i int) string { return r.Partitions[i].Topic }, func(topic string, from, to int) {
+		printTopicHeader(w, topic, from == 0)
+
+		fmt.Fprintf(w, "%-12s %-10s %-10s %-10s -> %s\n", "partition", "oldest", "newest", "offset", "verdict")
+		for _, p := range r.Partitions[from:to] {
+			fmt.Fprintf(w, "p:%-10d %-10d %-10d %-10d -> %s", p.Partition, p.Oldest, p.Newest, p.Offset, p.Verdict)
+			if p.Verdict != koff.InRange.String() {
+				fmt.Fprintf(w, "   !!!!\n")
+			} else {
+				fmt.Fprintf(w, "\n")
+			}
+		}
+	})
+}
+
+func (r *checkOffsetResult) records() []interface{} {
+	var res []interface{}
+	for _, p := range r.Partitions {
+		res = append(res, p)
+	}
+	return res
+}
+
+type groupRecord struct {
+	Group        string `json:"group"`
+	ProtocolType string `json:"protocol_type"`
+}
+
+// listGroupsResult is the result of list-groups.
+type listGroupsResult struct {
+	Groups []groupRecord `json:"groups"`
+	// Brokers are the brokers which failed to list their consumer groups, whose groups are missing.
+	Brokers []brokerErrorRecord `json:"brokers"`
+}
+
+func (r *listGroupsResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%-40s %-10s\n", "group", "protocol")
+	for _, g := range r.Groups {
+		fmt.Fprintf(w, "%-40s %-10s\n", g.Group, g.ProtocolType)
+	}
+
+	if len(r.Brokers) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n")
+	for _, b := range r.Brokers {
+		fmt.Fprintf(w, "b:%-8d unreachable: %s   !!!!\n", b.ID, b.Error)
+	}
+	fmt.Fprintf(w, "\n%d unreachable broker(s)   !!!!\n", len(r.Brokers))
+}
+
+func (r *listGroupsResult) records() []interface{} {
+	var res []interface{}
+	for _, g := range r.Groups {
+		res = append(res, g)
+	}
+	return res
+}
+
+type memberRecord struct {
+	Group      string `json:"group"`
+	MemberID   string `json:"member_id"`
+	ClientID   string `json:"client_id"`
+	ClientHost string `json:"client_host"`
+	Assignment string `json:"assignment"`
+}
+
+// describeGroupResult is the result of describe-group.
+type describeGroupResult struct {
+	Group        string         `json:"group"`
+	State        string         `json:"state"`
+	ProtocolType string         `json:"protocol_type"`
+	Protocol     string         `json:"protocol"`
+	Members      []memberRecord `json:"members"`
+}
+
+func (r *describeGroupResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%-10s %s\n", "group", r.Group)
+	fmt.Fprintf(w, "%-10s %s\n", "state", r.State)
+	fmt.Fprintf(w, "%-10s %s\n", "protocol", r.ProtocolType+"/"+r.Protocol)
+	fmt.Fprintf(w, "%-10s %d\n", "members", len(r.Members))
+
+	if len(r.Members) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%-50s %-20s %-20s %s\n", "member", "client", "host", "assignment")
+	for _, m := range r.Members {
+		fmt.Fprintf(w, "%-50s %-20s %-20s %s\n", m.MemberID, m.ClientID, m.ClientHost, m.Assignment)
+	}
+}
+
+func (r *describeGroupResult) records() []interface{} {
+	var res []interface{}
+	for _, m := range r.Members {
+		res = append(res, m)
+	}
+	return res
+}
+
+type resetRecord struct {
+	ConsumerGroup string `json:"consumer_group"`
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Before        int64  `json:"before"`
+	After         int64  `json:"after"`
+	Clamped       bool   `json:"clamped,omitempty"`
+}
+
+// resetResult is the result of reset-offsets.
+type resetResult struct {
+	ConsumerGroup string        `json:"consumer_group"`
+	Strategy      string        `json:"strategy"`
+	DryRun        bool          `json:"dry_run"`
+	Partitions    []resetRecord `json:"partitions"`
+}
+
+func (r *resetResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "consumer group: %s\n\n", r.ConsumerGroup)
+
+	eachTopic(len(r.Partitions), func(i int) string { return r.Partitions[i].Topic }, func(topic string, from, to int) {
+		printTopicHeader(w, topic, from == 0)
+
+		fmt.Fprintf(w, "%-12s %-10s -> %s\n", "partition", "before", "after")
+		for _, p := range r.Partitions[from:to] {
+			if p.Clamped {
+				fmt.Fprintf(w, "p:%-10d %-10d -> %-10d clamped to the available range   !!!!\n", p.Partition, p.Before, p.After)
+			} else {
+				fmt.Fprintf(w, "p:%-10d %-10d -> %d\n", p.Partition, p.Before, p.After)
+			}
+		}
+	})
+
+	var clamped int
+	for _, p := range r.Partitions {
+		if p.Clamped {
+			clamped++
+		}
+	}
+	if clamped > 0 {
+		fmt.Fprintf(w, "\n%d offset(s) out of the available range were clamped   !!!!\n", clamped)
+	}
+
+	if r.DryRun {
+		fmt.Fprintf(w, "\ndry run of reset %s, use -execute to commit the new offsets\n", r.Strategy)
+	}
+}
+
+func (r *resetResult) records() []interface{} {
+	var res []interface{}
+	for _, p := range r.Partitions {
+		res = append(res, p)
+	}
+	return res
+}
+
+type importRecord struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Current   int64  `json:"current"`
+	New       int64  `json:"new"`
+	Oldest    int64  `json:"oldest"`
+	Newest    int64  `json:"newest"`
+	Verdict   string `json:"verdict"`
+}
+
+// importResult is the result of import-offsets.
+type importResult struct {
+	ConsumerGroup string         `json:"consumer_group"`
+	DryRun        bool           `json:"dry_run"`
+	Invalid       int            `json:"invalid"`
+	Offsets       []importRecord `json:"offsets"`
+}
+
+func (r *importResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%-30s %-12s %-10s -> %-10s %s\n", "topic", "partition", "current", "new", "range")
+	for _, i := range r.Offsets {
+		fmt.Fprintf(w, "%-30s p:%-10d %-10d -> %-10d %s", i.Topic, i.Partition, i.Current, i.New, i.Verdict)
+		if i.Verdict != koff.InRange.String() {
+			fmt.Fprintf(w, "   !!!!\n")
+		} else {
+			fmt.Fprintf(w, "\n")
+		}
+	}
+
+	if r.DryRun && r.Invalid == 0 {
+		fmt.Fprintf(w, "\ndry run of import into %s, use -execute to commit the new offsets\n", r.ConsumerGroup)
+	}
+}
+
+func (r *importResult) records() []interface{} {
+	var res []interface{}
+	for _, i := range r.Offsets {
+		res = append(res, i)
+	}
+	return res
+}
+
+type copyRecord struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Metadata  string `json:"metadata"`
+}
+
+// copyResult is the result of copy-group.
+type copyResult struct {
+	Source  string       `json:"source"`
+	Target  string       `json:"target"`
+	Offsets []copyRecord `json:"offsets"`
+}
+
+func (r *copyResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "copied offsets of %s to %s\n\n", r.Source, r.Target)
+	fmt.Fprintf(w, "%-30s %-12s %-10s\n", "topic", "partition", "offset")
+	for _, o := range r.Offsets {
+		fmt.Fprintf(w, "%-30s p:%-10d %-10d\n", o.Topic, o.Partition, o.Offset)
+	}
+}
+
+func (r *copyResult) records() []interface{} {
+	var res []interface{}
+	for _, o := range r.Offsets {
+		res = append(res, o)
+	}
+	return res
+}
+
+type statusRecord struct {
+	ConsumerGroup string `json:"consumer_group"`
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Lag           int64  `json:"lag"`
+	Status        string `json:"status"`
+	Reason        string `json:"reason"`
+	Samples       int    `json:"samples"`
+	Error         string `json:"error,omitempty"`
+	// OldestError is set if the oldest offset of the partition is unknown, the status does not check the retention then.
+	OldestError string `json:"oldest_error,omitempty"`
+}
+
+// statusResult is the result of status.
+type statusResult struct {
+	ConsumerGroup string         `json:"consumer_group"`
+	Topic         string         `json:"topic"`
+	Status        string         `json:"status"`
+	Partitions    []statusRecord `json:"partitions"`
+}
+
+func (r *statusResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%s on %s: %s\n\n", r.ConsumerGroup, r.Topic, r.Status)
+	fmt.Fprintf(w, "%-12s %-10s %-10s %s\n", "partition", "lag", "status", "reason")
+	for _, p := range r.Partitions {
+		if p.Error != "" {
+			fmt.Fprintf(w, "p:%-10d error: %s   !!!!\n", p.Partition, p.Error)
+			continue
+		}
+
+		fmt.Fprintf(w, "p:%-10d %-10d %-10s %s", p.Partition, p.Lag, p.Status, p.Reason)
+		if p.OldestError != "" {
+			fmt.Fprintf(w, ", oldest offset unknown: %s", p.OldestError)
+		}
+		if p.Status != koff.StatusOK.String() || p.OldestError != "" {
+			fmt.Fprintf(w, "   !!!!\n")
+		} else {
+			fmt.Fprintf(w, "\n")
+		}
+	}
+}
+
+func (r *statusResult) records() []interface{} {
+	var res []interface{}
+	for _, p := range r.Partitions {
+		res = append(res, p)
+	}
+	return res
+}
+
+type overviewRecord struct {
+	ConsumerGroup     string `json:"consumer_group"`
+	Topic             string `json:"topic"`
+	TotalLag          int64  `json:"total_lag"`
+	MaxLag            int64  `json:"max_lag"`
+	LaggingPartitions int    `json:"lagging_partitions"`
+	Partitions        int    `json:"partitions"`
+	Error             string `json:"error,omitempty"`
+}
+
+// brokerErrorRecord is a broker which could not be queried.
+type brokerErrorRecord struct {
+	ID    int32  `json:"id"`
+	Error string `json:"error"`
+}
+
+// overviewResult is the result of overview.
+type overviewResult struct {
+	Lagging int              `json:"lagging"`
+	Rows    []overviewRecord `json:"rows"`
+	// Brokers are the brokers which failed to list their consumer groups, whose groups are missing from the rows.
+	Brokers []brokerErrorRecord `json:"brokers"`
+}
+
+func (r *overviewResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%-40s %-30s %-12s %-12s %s\n", "group", "topic", "total lag", "max lag", "lagging")
+	for _, l := range r.Rows {
+		if l.Error != "" {
+			fmt.Fprintf(w, "%-40s error: %s   !!!!\n", l.ConsumerGroup, l.Error)
+			continue
+		}
+
+		fmt.Fprintf(w, "%-40s %-30s %-12d %-12d %d/%d", l.ConsumerGroup, l.Topic, l.TotalLag, l.MaxLag, l.LaggingPartitions, l.Partitions)
+		if l.LaggingPartitions > 0 {
+			fmt.Fprintf(w, "   !!!!\n")
+		} else {
+			fmt.Fprintf(w, "\n")
+		}
+	}
+
+	if len(r.Brokers) > 0 {
+		fmt.Fprintf(w, "\n")
+	}
+	for _, b := range r.Brokers {
+		fmt.Fprintf(w, "b:%-8d unable to list consumer groups: %s   !!!!\n", b.ID, b.Error)
+	}
+}
+
+func (r *overviewResult) records() []interface{} {
+	var res []interface{}
+	for _, l := range r.Rows {
+		res = append(res, l)
+	}
+	return res
+}
+
+type topicStatsRecord struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Oldest    int64  `json:"oldest"`
+	Newest    int64  `json:"newest"`
+	Messages  int64  `json:"messages"`
+	Empty     bool   `json:"empty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// topicStats is the distribution of the retained messages across the partitions of a topic.
+type topicStats struct {
+	Topic  string  `json:"topic"`
+	Total  int64   `json:"total"`
+	Min    int64   `json:"min"`
+	Max    int64   `json:"max"`
+	Mean   float64 `json:"mean"`
+	Stddev float64 `json:"stddev"`
+	// Skew is the ratio of the largest partition to the mean, 1 being perfectly balanced.
+	Skew       float64            `json:"skew"`
+	Empty      int                `json:"empty"`
+	Partitions []topicStatsRecord `json:"partitions"`
+}
+
+// topicStatsResult is the result of topic-stats.
+type topicStatsResult struct {
+	Topics []topicStats `json:"topics"`
+}
+
+func (r *topicStatsResult) printTable(w io.Writer) {
+	for i, t := range r.Topics {
+		printTopicHeader(w, t.Topic, i == 0)
+
+		fmt.Fprintf(w, "%-12s %-12s %-12s %s\n", "partition", "oldest", "newest", "messages")
+		for _, p := range t.Partitions {
+			switch {
+			case p.Error != "":
+				fmt.Fprintf(w, "p:%-10d error: %s   !!!!\n", p.Partition, p.Error)
+			case p.Empty:
+				fmt.Fprintf(w, "p:%-10d %-12d %-12d %-12d empty   !!!!\n", p.Partition, p.Oldest, p.Newest, p.Messages)
+			default:
+				fmt.Fprintf(w, "p:%-10d %-12d %-12d %d\n", p.Partition, p.Oldest, p.Newest, p.Messages)
+			}
+		}
+
+		fmt.Fprintf(w, "\ntotal %d, min %d, max %d, mean %.1f, stddev %.1f, skew %.2f", t.Total, t.Min, t.Max, t.Mean, t.Stddev, t.Skew)
+		if t.Empty > 0 {
+			fmt.Fprintf(w, ", %d empty partition(s)   !!!!\n", t.Empty)
+		} else {
+			fmt.Fprintf(w, "\n")
+		}
+	}
+}
+
+func (r *topicStatsResult) records() []interface{} {
+	var res []interface{}
+	for _, t := range r.Topics {
+		for _, p := range t.Partitions {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+type replicaRecord struct {
+	Topic              string `json:"topic"`
+	Partition          int32  `json:"partition"`
+	Leader             int32  `json:"leader"`
+	Replicas           string `json:"replicas"`
+	ISR                string `json:"isr"`
+	Offline            bool   `json:"offline"`
+	UnderReplicated    bool   `json:"under_replicated"`
+	NonPreferredLeader bool   `json:"non_preferred_leader"`
+}
+
+func (r replicaRecord) problems() string {
+	var res []string
+	if r.Offline {
+		res = append(res, "offline")
+	}
+	if r.UnderReplicated {
+		res = append(res, "under replicated")
+	}
+	if r.NonPreferredLeader {
+		res = append(res, "non preferred leader")
+	}
+	return strings.Join(res, ", ")
+}
+
+// describeTopicResult is the result of describe-topic.
+type describeTopicResult struct {
+	Cluster            bool            `json:"cluster"`
+	Offline            int             `json:"offline"`
+	UnderReplicated    int             `json:"under_replicated"`
+	NonPreferredLeader int             `json:"non_preferred_leader"`
+	Partitions         []replicaRecord `json:"partitions"`
+}
+
+func (r *describeTopicResult) printTable(w io.Writer) {
+	if r.Cluster && len(r.Partitions) == 0 {
+		fmt.Fprintf(w, "no offline or under replicated partition\n")
+		return
+	}
+
+	eachTopic(len(r.Partitions), func(i int) string { return r.Partitions[i].Topic }, func(topic string, from, to int) {
+		printTopicHeader(w, topic, from == 0)
+
+		fmt.Fprintf(w, "%-12s %-10s %-20s %s\n", "partition", "leader", "replicas", "isr")
+		for _, p := range r.Partitions[from:to] {
+			leader := strconv.Itoa(int(p.Leader))
+			if p.Offline {
+				leader = "none"
+			}
+
+			if problems := p.problems(); problems != "" {
+				fmt.Fprintf(w, "p:%-10d %-10s %-20s %-20s %s   !!!!\n", p.Partition, leader, p.Replicas, p.ISR, problems)
+			} else {
+				fmt.Fprintf(w, "p:%-10d %-10s %-20s %s\n", p.Partition, leader, p.Replicas, p.ISR)
+			}
+		}
+	})
+
+	fmt.Fprintf(w, "\n%d offline, %d under replicated, %d with a non preferred leader\n", r.Offline, r.UnderReplicated, r.NonPreferredLeader)
+}
+
+func (r *describeTopicResult) records() []interface{} {
+	var res []interface{}
+	for _, p := range r.Partitions {
+		res = append(res, p)
+	}
+	return res
+}
+
+type brokerRecord struct {
+	ID             int32  `json:"id"`
+	Addr           string `json:"addr"`
+	ListOffsets    string `json:"list_offsets"`
+	OffsetFetch    string `json:"offset_fetch"`
+	OffsetCommit   string `json:"offset_commit"`
+	DescribeGroups string `json:"describe_groups"`
+	Error          string `json:"error,omitempty"`
+}
+
+// brokersResult is the result of brokers.
+type brokersResult struct {
+	Unreachable int            `json:"unreachable"`
+	Brokers     []brokerRecord `json:"brokers"`
+}
+
+func (r *brokersResult) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%-10s %-30s %-14s %-14s %-14s %s\n", "broker", "address", "list offsets", "offset fetch", "offset commit", "describe groups")
+	for _, b := range r.Brokers {
+		if b.Error != "" {
+			fmt.Fprintf(w, "b:%-8d %-30s unreachable: %s   !!!!\n", b.ID, b.Addr, b.Error)
+			continue
+		}
+
+		fmt.Fprintf(w, "b:%-8d %-30s %-14s %-14s %-14s %s\n", b.ID, b.Addr, b.ListOffsets, b.OffsetFetch, b.OffsetCommit, b.DescribeGroups)
+	}
+
+	if r.Unreachable > 0 {
+		fmt.Fprintf(w, "\n%d unreachable broker(s)   !!!!\n", r.Unreachable)
+	}
+}
+
+func (r *brokersResult) records() []interface{} {
+	var res []interface{}
+	for _, b := range r.Brokers {
+		res = append(res, b)
+	}
+	return res
+}
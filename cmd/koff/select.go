@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/vrischmann/koff"
+)
+
+// partitionSelection is the parsed -p flag, nil meaning all the partitions.
+var partitionSelection []int32
+
+// target is a selected topic along with its selected partitions.
+type target struct {
+	topic      string
+	partitions []int32
+}
+
+// selectTargets resolves the topic and partition selection against the metadata of the cluster.
+//
+// Topics which have none of the selected partitions are left out.
+func selectTargets(ctx context.Context, k *koff.Koff) ([]target, error) {
+	sel, err := koff.ParseSelector(flTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []target
+	for _, topic := range k.SelectTopics(sel) {
+		partitions, err := k.SelectPartitionsContext(ctx, topic, partitionSelection)
+		if err != nil {
+			return nil, err
+		}
+		if len(partitions) == 0 {
+			continue
+		}
+
+		res = append(res, target{topic: topic, partitions: partitions})
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no partition of a topic matches %q", flTopic)
+	}
+
+	return res, nil
+}
+
+// targetTopics returns the names of the topics of the targets.
+func targetTopics(targets []target) []string {
+	var res []string
+	for _, t := range targets {
+		res = append(res, t.topic)
+	}
+	return res
+}
+
+// selectGroups resolves the consumer group selection.
+//
+// If some brokers failed to list their groups, the error is logged and the groups of the others are used.
+func selectGroups(ctx context.Context, k *koff.Koff) ([]string, error) {
+	sel, err := koff.ParseSelector(flConsumerGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := k.SelectConsumerGroupsContext(ctx, sel)
+	if _, ok := err.(koff.BrokerErrors); ok && len(groups) > 0 {
+		log.Printf("unable to list the consumer groups of some brokers. err=%v", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no consumer group matches %q", flConsumerGroup)
+	}
+
+	return groups, nil
+}
+
+// selectGroup resolves the consumer group selection for the commands which only work on a single group.
+func selectGroup(ctx context.Context, k *koff.Koff) (string, error) {
+	groups, err := selectGroups(ctx, k)
+	if err != nil {
+		return "", err
+	}
+
+	if len(groups) > 1 {
+		return "", fmt.Errorf("%q matches %d consumer groups, only one is supported", flConsumerGroup, len(groups))
+	}
+
+	return groups[0], nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vrischmann/koff"
+)
+
+// metric is a single sample of the Prometheus text format.
+type metric struct {
+	labels []string
+	value  float64
+}
+
+// metricFamily holds all the samples of a metric name.
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	metrics []metric
+}
+
+func (f *metricFamily) add(value float64, labels ...string) {
+	f.metrics = append(f.metrics, metric{labels: labels, value: value})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (f *metricFamily) writeTo(buf *bytes.Buffer) {
+	if len(f.metrics) == 0 {
+		return
+	}
+
+	fmt.Fprintf(buf, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", f.name, f.typ)
+	for _, m := range f.metrics {
+		buf.WriteString(f.name)
+		if len(m.labels) > 0 {
+			buf.WriteByte('{')
+			for i := 0; i < len(m.labels); i += 2 {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, `%s="%s"`, m.labels[i], labelEscaper.Replace(m.labels[i+1]))
+			}
+			buf.WriteByte('}')
+		}
+		fmt.Fprintf(buf, " %s\n", strconv.FormatFloat(m.value, 'g', -1, 64))
+	}
+}
+
+// exporter periodically collects the offsets of the cluster and serves them in the Prometheus text format.
+//
+// The same Koff instance is reused for every collection; scrapes only read the result of the last collection.
+type exporter struct {
+	k *koff.Koff
+
+	mu          sync.RWMutex
+	body        []byte
+	collections int64
+	errors      int64
+}
+
+func newExporter(k *koff.Koff) *exporter {
+	return &exporter{k: k}
+}
+
+func (e *exporter) collect() {
+	start := time.Now()
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	oldest := &metricFamily{name: "koff_topic_partition_oldest_offset", help: "Oldest offset available in the partition.", typ: "gauge"}
+	newest := &metricFamily{name: "koff_topic_partition_newest_offset", help: "Newest offset available in the partition.", typ: "gauge"}
+	committed := &metricFamily{name: "koff_consumer_group_committed_offset", help: "Last offset committed by the consumer group.", typ: "gauge"}
+	lag := &metricFamily{name: "koff_consumer_group_lag", help: "Number of messages the consumer group is behind the newest offset.", typ: "gauge"}
+	collectErrors := &metricFamily{name: "koff_collect_error", help: "Whether the last collection of a stage failed.", typ: "gauge"}
+
+	// report records the outcome of every stage, so a stage which failed before is set back to 0 once it succeeds.
+	var nbErrors int64
+	report := func(err error, labels ...string) {
+		if err == nil {
+			collectErrors.add(0, labels...)
+			return
+		}
+
+		log.Printf("collect error: %v", err)
+		nbErrors++
+		collectErrors.add(1, labels...)
+	}
+
+	err := e.k.InitContext(ctx)
+	report(err, "stage", "metadata")
+
+	newestOffsets := make(map[string]map[int32]int64)
+	for _, topic := range e.k.Topics() {
+		offsets, err := e.k.GetOldestOffsetsContext(ctx, topic)
+		report(err, "stage", "oldest_offsets", "topic", topic)
+		for _, p := range sortedPartitions(offsets) {
+			oldest.add(float64(offsets[p]), "topic", topic, "partition", strconv.Itoa(int(p)))
+		}
+
+		offsets, err = e.k.GetNewestOffsetsContext(ctx, topic)
+		report(err, "stage", "newest_offsets", "topic", topic)
+		for _, p := range sortedPartitions(offsets) {
+			newest.add(float64(offsets[p]), "topic", topic, "partition", strconv.Itoa(int(p)))
+		}
+		newestOffsets[topic] = offsets
+	}
+
+	groups, err := e.k.ListConsumerGroupsContext(ctx)
+	report(err, "stage", "list_groups")
+
+	var groupNames []string
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+
+	sort.Strings(groupNames)
+
+	for _, group := range groupNames {
+		offsets, err := e.k.GetConsumerGroupOffsetsByTopicContext(ctx, group, flVersion)
+		report(err, "stage", "committed_offsets", "group", group)
+
+		var topics []string
+		for topic := range offsets {
+			topics = append(topics, topic)
+		}
+
+		sort.Strings(topics)
+
+		for _, topic := range topics {
+			for _, p := range sortedPartitions(offsets[topic]) {
+				partition := strconv.Itoa(int(p))
+				offset := offsets[topic][p]
+
+				committed.add(float64(offset), "group", group, "topic", topic, "partition", partition)
+				if v, ok := newestOffsets[topic][p]; ok {
+					if l, ok := koff.Lag(v, offset); ok {
+						lag.add(float64(l), "group", group, "topic", topic, "partition", partition)
+					}
+				}
+			}
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.collections++
+	e.errors += nbErrors
+
+	var buf bytes.Buffer
+	for _, f := range []*metricFamily{oldest, newest, committed, lag, collectErrors} {
+		f.writeTo(&buf)
+	}
+
+	stats := []*metricFamily{
+		{name: "koff_collections_total", help: "Number of collections since the start.", typ: "counter"},
+		{name: "koff_collect_errors_total", help: "Number of collection errors since the start.", typ: "counter"},
+		{name: "koff_last_collect_duration_seconds", help: "Duration of the last collection.", typ: "gauge"},
+		{name: "koff_last_collect_timestamp_seconds", help: "Unix time of the last collection.", typ: "gauge"},
+	}
+	stats[0].add(float64(e.collections))
+	stats[1].add(float64(e.errors))
+	stats[2].add(time.Since(start).Seconds())
+	stats[3].add(float64(start.Unix()))
+	for _, f := range stats {
+		f.writeTo(&buf)
+	}
+
+	e.body = buf.Bytes()
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(e.body)
+}
+
+func sortedPartitions(offsets map[int32]int64) []int32 {
+	var keys []int
+	for k := range offsets {
+		keys = append(keys, int(k))
+	}
+
+	sort.Ints(keys)
+
+	res := make([]int32, len(keys))
+	for i, k := range keys {
+		res[i] = int32(k)
+	}
+
+	return res
+}
+
+func serve() error {
+	k := koff.New(client)
+
+	e := newExporter(k)
+	e.collect()
+
+	go func() {
+		ticker := time.NewTicker(flInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			e.collect()
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+
+	log.Printf("serving metrics on http://%s/metrics", flListen)
+
+	return http.ListenAndServe(flListen, mux)
+}
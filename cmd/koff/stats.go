@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"sort"
+
+	"github.com/vrischmann/koff"
+)
+
+// newTopicStatsRecord computes the number of messages retained by the partition from its oldest and newest offsets.
+//
+// The newest offset is the one of the last message, so an empty partition has a newest offset one below its oldest offset.
+func newTopicStatsRecord(topic string, partition int32, oldest, newest int64) topicStatsRecord {
+	messages := newest + 1 - oldest
+	if messages < 0 {
+		messages = 0
+	}
+
+	return topicStatsRecord{
+		Topic:     topic,
+		Partition: partition,
+		Oldest:    oldest,
+		Newest:    newest,
+		Messages:  messages,
+		Empty:     messages == 0,
+	}
+}
+
+// newTopicStats computes the statistics of the topic from the retained messages of its partitions.
+func newTopicStats(topic string, partitions []topicStatsRecord) topicStats {
+	res := topicStats{Topic: topic, Partitions: partitions}
+
+	var counts []float64
+	for _, p := range partitions {
+		if p.Error != "" {
+			continue
+		}
+
+		if len(counts) == 0 || p.Messages < res.Min {
+			res.Min = p.Messages
+		}
+		if p.Messages > res.Max {
+			res.Max = p.Messages
+		}
+		if p.Messages == 0 {
+			res.Empty++
+		}
+
+		res.Total += p.Messages
+		counts = append(counts, float64(p.Messages))
+	}
+
+	if len(counts) == 0 {
+		return res
+	}
+
+	res.Mean = float64(res.Total) / float64(len(counts))
+
+	var variance float64
+	for _, c := range counts {
+		variance += (c - res.Mean) * (c - res.Mean)
+	}
+	res.Stddev = math.Sqrt(variance / float64(len(counts)))
+
+	if res.Mean > 0 {
+		res.Skew = float64(res.Max) / res.Mean
+	}
+
+	return res
+}
+
+// topicStatistics reports the number of retained messages of each partition and their distribution across the topic.
+func topicStatistics() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	res := &topicStatsResult{Topics: []topicStats{}}
+	for _, t := range targets {
+		oldest, err := k.GetOldestOffsetsContext(ctx, t.topic, t.partitions...)
+		failed, err := partitionErrors(err)
+		if err != nil {
+			return err
+		}
+
+		newest, err := k.GetNewestOffsetsContext(ctx, t.topic, t.partitions...)
+		failedNewest, err := partitionErrors(err)
+		if err != nil {
+			return err
+		}
+
+		if failed == nil {
+			failed = make(map[int32]error)
+		}
+		for p, err := range failedNewest {
+			if _, ok := failed[p]; !ok {
+				failed[p] = err
+			}
+		}
+
+		var keys []int
+		for _, p := range t.partitions {
+			keys = append(keys, int(p))
+		}
+
+		sort.Ints(keys)
+
+		var partitions []topicStatsRecord
+		for _, part := range keys {
+			p := int32(part)
+
+			rec := topicStatsRecord{Topic: t.topic, Partition: p}
+			if err, ok := failed[p]; ok {
+				rec.Error = err.Error()
+			} else {
+				rec = newTopicStatsRecord(t.topic, p, oldest[p], newest[p])
+			}
+			partitions = append(partitions, rec)
+		}
+
+		res.Topics = append(res.Topics, newTopicStats(t.topic, partitions))
+	}
+
+	return render(res)
+}
+
+func topicStatsCommand() error {
+	if err := fsTS.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return topicStatistics()
+}
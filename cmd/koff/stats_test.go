@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTopicStatsRecord(t *testing.T) {
+	rec := newTopicStatsRecord("orders", 0, 1200, 58199)
+	require.Equal(t, int64(57000), rec.Messages)
+	require.False(t, rec.Empty)
+
+	// The newest offset of an empty partition is the one before its oldest offset.
+	rec = newTopicStatsRecord("orders", 1, 900, 899)
+	require.Equal(t, int64(0), rec.Messages)
+	require.True(t, rec.Empty)
+
+	rec = newTopicStatsRecord("orders", 2, 0, -1)
+	require.Equal(t, int64(0), rec.Messages)
+	require.True(t, rec.Empty)
+}
+
+func TestNewTopicStats(t *testing.T) {
+	stats := newTopicStats("orders", []topicStatsRecord{
+		newTopicStatsRecord("orders", 0, 1200, 58199),
+		newTopicStatsRecord("orders", 1, 900, 899),
+		newTopicStatsRecord("orders", 2, 1100, 4099),
+		{Topic: "orders", Partition: 3, Error: "kafka server: Request timed out."},
+	})
+
+	require.Equal(t, int64(60000), stats.Total)
+	require.Equal(t, int64(0), stats.Min)
+	require.Equal(t, int64(57000), stats.Max)
+	require.Equal(t, 1, stats.Empty)
+	require.InDelta(t, 20000.0, stats.Mean, 0.01)
+	require.InDelta(t, 26191.6, stats.Stddev, 0.1)
+	require.InDelta(t, 2.85, stats.Skew, 0.01)
+
+	stats = newTopicStats("balanced", []topicStatsRecord{
+		newTopicStatsRecord("balanced", 0, 0, 99),
+		newTopicStatsRecord("balanced", 1, 0, 99),
+	})
+
+	require.Equal(t, int64(100), stats.Min)
+	require.Equal(t, 0, stats.Empty)
+	require.InDelta(t, 1.0, stats.Skew, 0.001)
+	require.InDelta(t, 0.0, stats.Stddev, 0.001)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// outputTemplate is the template set with -template or -template-file, nil if none is set.
+var outputTemplate *template.Template
+
+// templateFuncs are the helpers available in the output templates.
+var templateFuncs = template.FuncMap{
+	"sortBy":  sortBy,
+	"reverse": reverse,
+	"sum":     sum,
+	"max":     max,
+	"human":   human,
+	"seconds": seconds,
+}
+
+func parseOutputTemplate() error {
+	if flTemplate != "" && flTemplateFile != "" {
+		return errors.New("template and template file can't be used together")
+	}
+
+	text := flTemplate
+	if flTemplateFile != "" {
+		data, err := ioutil.ReadFile(flTemplateFile)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+
+	if text == "" {
+		return nil
+	}
+
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("unable to parse template. err=%v", err)
+	}
+	outputTemplate = tmpl
+
+	return nil
+}
+
+// fieldValues returns the values of the field of every element of the slice.
+func fieldValues(list interface{}, field string) ([]reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("%s is not a list", v.Kind())
+	}
+
+	res := make([]reflect.Value, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := reflect.Indirect(v.Index(i))
+		if elem.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("%s is not a struct", elem.Kind())
+		}
+
+		f := elem.FieldByName(field)
+		if !f.IsValid() {
+			return nil, fmt.Errorf("%q field does not exist", field)
+		}
+		res[i] = f
+	}
+
+	return res, nil
+}
+
+func toFloat(v reflect.Value) (float64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	default:
+		return 0, fmt.Errorf("%s is not a number", v.Kind())
+	}
+}
+
+type byFieldValue struct {
+	elems  []interface{}
+	values []reflect.Value
+}
+
+func (s byFieldValue) Len() int { return len(s.elems) }
+func (s byFieldValue) Swap(i, j int) {
+	s.elems[i], s.elems[j] = s.elems[j], s.elems[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+func (s byFieldValue) Less(i, j int) bool {
+	a, b := s.values[i], s.values[j]
+	if a.Kind() == reflect.String {
+		return a.String() < b.String()
+	}
+
+	x, _ := toFloat(a)
+	y, _ := toFloat(b)
+	return x < y
+}
+
+// sortBy returns the elements of the list sorted by the field, which must be a number or a string.
+func sortBy(field string, list interface{}) ([]interface{}, error) {
+	values, err := fieldValues(list, field)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(list)
+	elems := make([]interface{}, v.Len())
+	for i := range elems {
+		elems[i] = v.Index(i).Interface()
+		if values[i].Kind() == reflect.String {
+			continue
+		}
+		if _, err := toFloat(values[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Stable(byFieldValue{elems: elems, values: values})
+
+	return elems, nil
+}
+
+// reverse returns the elements of the list in reverse order.
+func reverse(list interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("%s is not a list", v.Kind())
+	}
+
+	res := make([]interface{}, v.Len())
+	for i := range res {
+		res[i] = v.Index(v.Len() - 1 - i).Interface()
+	}
+
+	return res, nil
+}
+
+// sum returns the sum of the field over the elements of the list. It is an integer if the field is.
+func sum(field string, list interface{}) (interface{}, error) {
+	values, err := fieldValues(list, field)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res      float64
+		integers = true
+	)
+	for _, v := range values {
+		f, err := toFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		res += f
+
+		if k := v.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+			integers = false
+		}
+	}
+
+	if integers {
+		return int64(res), nil
+	}
+	return res, nil
+}
+
+// max returns the maximum of the field over the elements of the list, 0 if it's empty.
+func max(field string, list interface{}) (interface{}, error) {
+	values, err := fieldValues(list, field)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	res := values[0]
+	for _, v := range values {
+		a, err := toFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := toFloat(res)
+		if a > b {
+			res = v
+		}
+	}
+
+	return res.Interface(), nil
+}
+
+// human formats a number with a k, M, G or T suffix, like 1.2M.
+func human(n interface{}) (string, error) {
+	f, err := toFloat(reflect.ValueOf(n))
+	if err != nil {
+		return "", err
+	}
+
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+
+	if f < 1000 {
+		return sign + strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	var unit string
+	for _, u := range []string{"k", "M", "G", "T"} {
+		f /= 1000
+		unit = u
+		// The number is rounded before choosing the unit, so 999950 is 1.0M and not 1000.0k.
+		if roundTenth(f) < 1000 {
+			break
+		}
+	}
+
+	return sign + strconv.FormatFloat(roundTenth(f), 'f', 1, 64) + unit, nil
+}
+
+// roundTenth rounds a positive number to one decimal.
+func roundTenth(f float64) float64 {
+	return math.Floor(f*10+0.5) / 10
+}
+
+// seconds formats a number of seconds as a duration, like 1h2m3s.
+func seconds(n interface{}) (string, error) {
+	f, err := toFloat(reflect.ValueOf(n))
+	if err != nil {
+		return "", err
+	}
+
+	return (time.Duration(f) * time.Second).String(), nil
+}
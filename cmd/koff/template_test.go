@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type templateRecord struct {
+	Name  string
+	Lag   int64
+	Ratio float64
+}
+
+var templateRecords = []templateRecord{
+	{"b", 300, 0.5},
+	{"a", 100, 1.5},
+	{"c", 200, 0.25},
+}
+
+func TestSortBy(t *testing.T) {
+	sorted, err := sortBy("Lag", templateRecords)
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{templateRecords[1], templateRecords[2], templateRecords[0]}, sorted)
+
+	sorted, err = sortBy("Name", templateRecords)
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{templateRecords[1], templateRecords[0], templateRecords[2]}, sorted)
+
+	reversed, err := reverse(sorted)
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{templateRecords[2], templateRecords[0], templateRecords[1]}, reversed)
+
+	_, err = sortBy("Unknown", templateRecords)
+	require.NotNil(t, err)
+
+	_, err = sortBy("Lag", 42)
+	require.NotNil(t, err)
+}
+
+func TestSumAndMax(t *testing.T) {
+	testCases := []struct {
+		field string
+		sum   interface{}
+		max   interface{}
+	}{
+		{"Lag", int64(600), int64(300)},
+		{"Ratio", 2.25, 1.5},
+	}
+
+	for _, tc := range testCases {
+		s, err := sum(tc.field, templateRecords)
+		require.Nil(t, err)
+		require.Equal(t, tc.sum, s, tc.field)
+
+		m, err := max(tc.field, templateRecords)
+		require.Nil(t, err)
+		require.Equal(t, tc.max, m, tc.field)
+	}
+
+	m, err := max("Lag", []templateRecord{})
+	require.Nil(t, err)
+	require.Equal(t, 0, m)
+
+	_, err = sum("Name", templateRecords)
+	require.NotNil(t, err)
+}
+
+func TestHuman(t *testing.T) {
+	testCases := []struct {
+		n   interface{}
+		exp string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{12.5, "12.5"},
+		{1000, "1.0k"},
+		{1234, "1.2k"},
+		{int64(-1500), "-1.5k"},
+		{999949, "999.9k"},
+		{999950, "1.0M"},
+		{999999, "1.0M"},
+		{1200000, "1.2M"},
+		{999950000, "1.0G"},
+		{2500000000000, "2.5T"},
+		{5000000000000000, "5000.0T"},
+	}
+
+	for _, tc := range testCases {
+		res, err := human(tc.n)
+		require.Nil(t, err)
+		require.Equal(t, tc.exp, res, "%v", tc.n)
+	}
+
+	_, err := human("foo")
+	require.NotNil(t, err)
+}
+
+func TestSeconds(t *testing.T) {
+	testCases := []struct {
+		n   interface{}
+		exp string
+	}{
+		{0, "0s"},
+		{45, "45s"},
+		{int64(3723), "1h2m3s"},
+		{90.0, "1m30s"},
+	}
+
+	for _, tc := range testCases {
+		res, err := seconds(tc.n)
+		require.Nil(t, err)
+		require.Equal(t, tc.exp, res, "%v", tc.n)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vrischmann/koff"
+)
+
+func formatBrokers(ids []int32) string {
+	var res []string
+	for _, id := range ids {
+		res = append(res, strconv.Itoa(int(id)))
+	}
+	return strings.Join(res, ",")
+}
+
+// addReplicas adds the records of the partitions of the topic to the result, sorted by partition.
+func (r *describeTopicResult) addReplicas(topic string, replicas map[int32]koff.PartitionReplicas) {
+	var keys []int
+	for k := range replicas {
+		keys = append(keys, int(k))
+	}
+
+	sort.Ints(keys)
+
+	for _, part := range keys {
+		pr := replicas[int32(part)]
+
+		r.Partitions = append(r.Partitions, replicaRecord{
+			Topic:              topic,
+			Partition:          int32(part),
+			Leader:             pr.Leader,
+			Replicas:           formatBrokers(pr.Replicas),
+			ISR:                formatBrokers(pr.ISR),
+			Offline:            pr.Offline,
+			UnderReplicated:    pr.UnderReplicated,
+			NonPreferredLeader: pr.NonPreferredLeader,
+		})
+
+		if pr.Offline {
+			r.Offline++
+		}
+		if pr.UnderReplicated {
+			r.UnderReplicated++
+		}
+		if pr.NonPreferredLeader {
+			r.NonPreferredLeader++
+		}
+	}
+}
+
+// describeTopic shows the leader, replicas and in sync replicas of the partitions of the selected topics,
+// or the under replicated partitions of the whole cluster.
+func describeTopic() error {
+	k := koff.New(client)
+
+	ctx, cancel := newContext()
+	defer cancel()
+
+	res := &describeTopicResult{Cluster: flCluster, Partitions: []replicaRecord{}}
+
+	if flCluster {
+		underReplicated, err := k.GetUnderReplicatedPartitionsContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		var topics []string
+		for topic := range underReplicated {
+			topics = append(topics, topic)
+		}
+
+		sort.Strings(topics)
+
+		for _, topic := range topics {
+			res.addReplicas(topic, underReplicated[topic])
+		}
+
+		return render(res)
+	}
+
+	if err := k.InitContext(ctx); err != nil {
+		return err
+	}
+
+	targets, err := selectTargets(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		replicas, err := k.DescribeTopicContext(ctx, t.topic)
+		if err != nil {
+			return err
+		}
+
+		selected := make(map[int32]koff.PartitionReplicas)
+		for _, p := range t.partitions {
+			if r, ok := replicas[p]; ok {
+				selected[p] = r
+			}
+		}
+
+		res.addReplicas(t.topic, selected)
+	}
+
+	return render(res)
+}
+
+func describeTopicCommand() error {
+	if err := fsDT.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	if err := initSarama(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return describeTopic()
+}
@@ -0,0 +1,249 @@
+package koff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// BrokerErrors is returned when some brokers could not be queried.
+//
+// It maps broker IDs to the error which occurred. The data of the other brokers is still returned alongside it.
+type BrokerErrors map[int32]error
+
+func (e BrokerErrors) Error() string {
+	var ids []int
+	for id := range e {
+		ids = append(ids, int(id))
+	}
+
+	sort.Ints(ids)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "unable to query %d broker(s).", len(ids))
+	for _, id := range ids {
+		fmt.Fprintf(&buf, " b:%d err=%v", id, e[int32(id)])
+	}
+
+	return buf.String()
+}
+
+func (k *Koff) connectBroker(ctx context.Context, broker *sarama.Broker) error {
+	return withContext(ctx, func() error {
+		if err := broker.Open(k.client.Config()); err != nil && err != sarama.ErrAlreadyConnected {
+			return err
+		}
+
+		_, err := broker.Connected()
+		return err
+	})
+}
+
+// ListConsumerGroups lists the consumer groups known by every broker of the cluster.
+//
+// Returns a map of consumer group to protocol type. If some brokers failed, the groups of the others are returned along with a BrokerErrors.
+func (k *Koff) ListConsumerGroups() (map[string]string, error) {
+	return k.ListConsumerGroupsContext(context.Background())
+}
+
+// ListConsumerGroupsContext is like ListConsumerGroups but honors the deadline and cancellation of ctx.
+func (k *Koff) ListConsumerGroupsContext(ctx context.Context) (map[string]string, error) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		res  = make(map[string]string)
+		errs = make(BrokerErrors)
+	)
+
+	for _, broker := range k.client.Brokers() {
+		wg.Add(1)
+		go func(broker *sarama.Broker) {
+			defer wg.Done()
+
+			resp, err := k.listGroups(ctx, broker)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs[broker.ID()] = err
+				return
+			}
+
+			for group, protocolType := range resp.Groups {
+				res[group] = protocolType
+			}
+		}(broker)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return res, errs
+	}
+
+	return res, nil
+}
+
+func (k *Koff) listGroups(ctx context.Context, broker *sarama.Broker) (*sarama.ListGroupsResponse, error) {
+	if err := k.connectBroker(ctx, broker); err != nil {
+		return nil, fmt.Errorf("unable to connect to broker. err=%v", err)
+	}
+
+	var resp *sarama.ListGroupsResponse
+	err := withContext(ctx, func() (err error) {
+		resp, err = broker.ListGroups(&sarama.ListGroupsRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list groups. err=%v", err)
+	}
+
+	if resp.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("unable to list groups. err=%v", resp.Err)
+	}
+
+	return resp, nil
+}
+
+// GroupMember describes a member of a consumer group.
+type GroupMember struct {
+	MemberID   string
+	ClientID   string
+	ClientHost string
+
+	// Subscriptions is the list of topics the member subscribed to.
+	Subscriptions []string
+	// Assignment maps topics to the partitions currently assigned to the member.
+	Assignment map[string][]int32
+}
+
+type membersByID []GroupMember
+
+func (m membersByID) Len() int           { return len(m) }
+func (m membersByID) Less(i, j int) bool { return m[i].MemberID < m[j].MemberID }
+func (m membersByID) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// ConsumerGroupDescription describes the state of a consumer group and its members.
+//
+// The generation of the group is not part of it because Kafka only gives it to the members of the group.
+type ConsumerGroupDescription struct {
+	Group        string
+	State        string
+	ProtocolType string
+	Protocol     string
+
+	// Members is sorted by member ID.
+	Members []GroupMember
+}
+
+// DescribeConsumerGroup retrieves the state of the given consumer group from its coordinator.
+//
+// The subscriptions and assignments of the members are only decoded for groups using the "consumer" protocol type.
+func (k *Koff) DescribeConsumerGroup(consumerGroup string) (*ConsumerGroupDescription, error) {
+	return k.DescribeConsumerGroupContext(context.Background(), consumerGroup)
+}
+
+// DescribeConsumerGroupContext is like DescribeConsumerGroup but honors the deadline and cancellation of ctx.
+func (k *Koff) DescribeConsumerGroupContext(ctx context.Context, consumerGroup string) (*ConsumerGroupDescription, error) {
+	offsetCoordinator, err := k.getOffsetCoordinator(ctx, consumerGroup)
+	if err != nil {
+		return nil, fmt.Errorf("unable to init offset coordinator. err=%v", err)
+	}
+
+	req := &sarama.DescribeGroupsRequest{}
+	req.AddGroup(consumerGroup)
+
+	var resp *sarama.DescribeGroupsResponse
+	err = withContext(ctx, func() (err error) {
+		resp, err = offsetCoordinator.DescribeGroups(req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe group %q. err=%v", consumerGroup, err)
+	}
+
+	if len(resp.Groups) != 1 {
+		return nil, fmt.Errorf("unable to describe group %q. err=%v", consumerGroup, sarama.ErrIncompleteResponse)
+	}
+
+	group := resp.Groups[0]
+	if group.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("unable to describe group %q. err=%v", consumerGroup, group.Err)
+	}
+
+	res := &ConsumerGroupDescription{
+		Group:        group.GroupId,
+		State:        group.State,
+		ProtocolType: group.ProtocolType,
+		Protocol:     group.Protocol,
+	}
+
+	for memberID, m := range group.Members {
+		member := GroupMember{
+			MemberID:   memberID,
+			ClientID:   m.ClientId,
+			ClientHost: m.ClientHost,
+		}
+
+		if group.ProtocolType == "consumer" {
+			if len(m.MemberMetadata) > 0 {
+				metadata, err := m.GetMemberMetadata()
+				if err != nil {
+					return nil, fmt.Errorf("unable to decode metadata of member %q. err=%v", memberID, err)
+				}
+				member.Subscriptions = metadata.Topics
+			}
+
+			if len(m.MemberAssignment) > 0 {
+				assignment, err := m.GetMemberAssignment()
+				if err != nil {
+					return nil, fmt.Errorf("unable to decode assignment of member %q. err=%v", memberID, err)
+				}
+				member.Assignment = assignment.Topics
+			}
+		}
+
+		res.Members = append(res.Members, member)
+	}
+
+	sort.Sort(membersByID(res.Members))
+
+	return res, nil
+}
+
+// ErrGroupHasActiveMembers is returned when refusing to change the offsets of a consumer group which still has active members.
+var ErrGroupHasActiveMembers = errors.New("consumer group has active members")
+
+// DescribeGroupError is returned when the members of a consumer group can't be checked because it could not be described,
+// like groups committing their offsets to ZooKeeper or on brokers older than Kafka 0.9.
+type DescribeGroupError struct {
+	Group string
+	Err   error
+}
+
+func (e *DescribeGroupError) Error() string {
+	return fmt.Sprintf("unable to check the members of consumer group %q. err=%v", e.Group, e.Err)
+}
+
+// checkNoActiveMembers returns ErrGroupHasActiveMembers if the consumer group has members, or a DescribeGroupError if it could not be described.
+func (k *Koff) checkNoActiveMembers(ctx context.Context, consumerGroup string) error {
+	desc, err := k.DescribeConsumerGroupContext(ctx, consumerGroup)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err != nil {
+		return &DescribeGroupError{Group: consumerGroup, Err: err}
+	}
+
+	if len(desc.Members) > 0 {
+		return ErrGroupHasActiveMembers
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package koff_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func putString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, int16(len(s)))
+	buf.WriteString(s)
+}
+
+func encodeMemberMetadata(topics ...string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int16(0))
+	binary.Write(&buf, binary.BigEndian, int32(len(topics)))
+	for _, topic := range topics {
+		putString(&buf, topic)
+	}
+	binary.Write(&buf, binary.BigEndian, int32(-1))
+	return buf.Bytes()
+}
+
+func encodeMemberAssignment(topic string, partitions ...int32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int16(0))
+	binary.Write(&buf, binary.BigEndian, int32(1))
+	putString(&buf, topic)
+	binary.Write(&buf, binary.BigEndian, int32(len(partitions)))
+	for _, p := range partitions {
+		binary.Write(&buf, binary.BigEndian, p)
+	}
+	binary.Write(&buf, binary.BigEndian, int32(-1))
+	return buf.Bytes()
+}
+
+func TestListConsumerGroups(t *testing.T) {
+	seedBroker := sarama.NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	broker1 := sarama.NewMockBroker(t, 2)
+	defer broker1.Close()
+	broker2 := sarama.NewMockBroker(t, 3)
+	defer broker2.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker1.Addr(), broker1.BrokerID())
+	metadataResponse.SetBroker(broker2.Addr(), broker2.BrokerID())
+	metadataResponse.SetLeader("foobar", 0, broker1.BrokerID())
+
+	seedBroker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadataResponse,
+	})
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ListGroupsRequest": sarama.NewMockWrapper(&sarama.ListGroupsResponse{
+			Groups: map[string]string{"myConsumerGroup": "consumer"},
+		}),
+	})
+	broker2.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ListGroupsRequest": sarama.NewMockWrapper(&sarama.ListGroupsResponse{
+			Groups: map[string]string{"myConnectGroup": "connect", "myOtherGroup": "consumer"},
+		}),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_9_0_0
+
+	client, err := sarama.NewClient([]string{seedBroker.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+
+	groups, err := k.ListConsumerGroups()
+	require.Nil(t, err)
+
+	require.Equal(t, 3, len(groups))
+	require.Equal(t, "consumer", groups["myConsumerGroup"])
+	require.Equal(t, "connect", groups["myConnectGroup"])
+	require.Equal(t, "consumer", groups["myOtherGroup"])
+}
+
+func TestDescribeConsumerGroup(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	desc, err := k.DescribeConsumerGroup("myConsumerGroup")
+	require.Nil(t, err)
+
+	require.Equal(t, "myConsumerGroup", desc.Group)
+	require.Equal(t, "Stable", desc.State)
+	require.Equal(t, "consumer", desc.ProtocolType)
+	require.Equal(t, "range", desc.Protocol)
+	require.Equal(t, 2, len(desc.Members))
+
+	m := desc.Members[0]
+	require.Equal(t, "consumer-1-a", m.MemberID)
+	require.Equal(t, "consumer-1", m.ClientID)
+	require.Equal(t, "/10.0.0.1", m.ClientHost)
+	require.Equal(t, []string{"foobar"}, m.Subscriptions)
+	require.Equal(t, map[string][]int32{"foobar": {0}}, m.Assignment)
+
+	m = desc.Members[1]
+	require.Equal(t, "consumer-2-b", m.MemberID)
+	require.Equal(t, map[string][]int32{"foobar": {1}}, m.Assignment)
+}
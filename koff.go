@@ -1,8 +1,14 @@
 package koff
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 )
@@ -12,120 +18,604 @@ type topicAndPartition struct {
 	partition int32
 }
 
+// withContext runs fn and waits for it to return or for ctx to be done, whichever happens first.
+//
+// sarama requests can't be cancelled: if ctx is done first, fn keeps running in the background and its result is dropped.
+func withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ctx.Done() == nil {
+		// The context can never be cancelled.
+		return fn()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DefaultMetadataTTL is how long the metadata of a topic is cached before being loaded again.
+const DefaultMetadataTTL = 5 * time.Minute
+
 // Koff provides method to get and compare offsets of consumer groups.
 type Koff struct {
 	client sarama.Client
 
-	pMu        sync.RWMutex
-	partitions map[string][]int32
+	pMu         sync.RWMutex
+	metadataTTL time.Duration
+	topics      []string
+	partitions  map[string]topicPartitions
+}
+
+// topicPartitions is the cached list of partitions of a topic.
+type topicPartitions struct {
+	partitions []int32
+	loadedAt   time.Time
 }
 
 // New creates a new Koff structure.
 func New(client sarama.Client) *Koff {
 	return &Koff{
-		client:     client,
-		partitions: make(map[string][]int32),
+		client:      client,
+		metadataTTL: DefaultMetadataTTL,
+		partitions:  make(map[string]topicPartitions),
 	}
 }
 
+// SetMetadataTTL sets how long the metadata of a topic is cached.
+//
+// A ttl of 0 disables the expiry, the metadata is then only loaded again by Refresh.
+//
+// When the metadata of a topic is loaded again because it expired, a change of its number of partitions is used
+// but not reported. Use Refresh to know which topics were expanded or deleted.
+func (k *Koff) SetMetadataTTL(ttl time.Duration) {
+	k.pMu.Lock()
+	defer k.pMu.Unlock()
+
+	k.metadataTTL = ttl
+}
+
 // Init initializes the state of the Koff instance.
 //
-// It queries the Kafka cluster for a list of topics and refreshes the metadata for each topic.
+// It only gets the list of topics known by the client. The metadata of a topic is loaded the first time
+// the topic is used and cached for the metadata TTL.
 func (k *Koff) Init() error {
-	topics, err := k.client.Topics()
+	return k.InitContext(context.Background())
+}
+
+// InitContext is like Init but honors the deadline and cancellation of ctx.
+func (k *Koff) InitContext(ctx context.Context) error {
+	var topics []string
+	err := withContext(ctx, func() (err error) {
+		topics, err = k.client.Topics()
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	if err := k.client.RefreshMetadata(topics...); err != nil {
+	sort.Strings(topics)
+
+	k.pMu.Lock()
+	defer k.pMu.Unlock()
+
+	k.topics = topics
+
+	return nil
+}
+
+// Topics returns the sorted list of topics known by the Koff instance.
+func (k *Koff) Topics() []string {
+	k.pMu.RLock()
+	defer k.pMu.RUnlock()
+
+	res := make([]string, len(k.topics))
+	copy(res, k.topics)
+
+	return res
+}
+
+// PartitionCountChange is the change of the number of partitions of a topic between two loads of its metadata.
+type PartitionCountChange struct {
+	Old int
+	New int
+}
+
+// Refresh loads again the metadata of the given topics, regardless of the metadata TTL.
+//
+// If no topic is provided, the list of topics is refreshed as well as the metadata of every topic already loaded.
+//
+// Returns a map of topics to partition count change, for the topics whose number of partitions changed.
+func (k *Koff) Refresh(topics ...string) (map[string]PartitionCountChange, error) {
+	return k.RefreshContext(context.Background(), topics...)
+}
+
+// RefreshContext is like Refresh but honors the deadline and cancellation of ctx.
+func (k *Koff) RefreshContext(ctx context.Context, topics ...string) (map[string]PartitionCountChange, error) {
+	if len(topics) > 0 {
+		return k.loadTopics(ctx, topics)
+	}
+
+	var all []string
+	err := withContext(ctx, func() (err error) {
+		// Topics being deleted can still be listed as unknown, the others are refreshed anyway.
+		if err := k.client.RefreshMetadata(); err != nil && err != sarama.ErrUnknownTopicOrPartition {
+			return err
+		}
+
+		all, err = k.client.Topics()
 		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to refresh the list of topics. err=%v", err)
 	}
 
+	sort.Strings(all)
+
+	k.pMu.Lock()
+	k.topics = all
+	for topic := range k.partitions {
+		topics = append(topics, topic)
+	}
+	k.pMu.Unlock()
+
+	if len(topics) <= 0 {
+		return make(map[string]PartitionCountChange), nil
+	}
+
+	return k.loadTopics(ctx, topics)
+}
+
+// loadTopics refreshes the metadata of the topics in a single request and caches their partitions.
+//
+// Topics which don't exist are removed from the cache.
+//
+// Returns a map of topics to partition count change, for the topics whose number of partitions changed.
+func (k *Koff) loadTopics(ctx context.Context, topics []string) (map[string]PartitionCountChange, error) {
+	err := withContext(ctx, func() error {
+		return k.client.RefreshMetadata(topics...)
+	})
+	// sarama still refreshes the metadata of the other topics when some of them don't exist, they are handled one by one below.
+	if err != nil && err != sarama.ErrUnknownTopicOrPartition {
+		return nil, fmt.Errorf("unable to refresh metadata of %d topic(s). err=%v", len(topics), err)
+	}
+
+	now := time.Now()
+
 	k.pMu.Lock()
 	defer k.pMu.Unlock()
 
+	res := make(map[string]PartitionCountChange)
 	for _, topic := range topics {
+		old, known := k.partitions[topic]
+
 		p, err := k.client.Partitions(topic)
-		if err != nil {
-			return err
+		switch {
+		case err == sarama.ErrUnknownTopicOrPartition:
+			delete(k.partitions, topic)
+			p = nil
+		case err != nil:
+			return nil, fmt.Errorf("unable to get partitions of %q. err=%v", topic, err)
+		default:
+			k.partitions[topic] = topicPartitions{partitions: p, loadedAt: now}
+		}
+
+		if known && len(old.partitions) != len(p) {
+			res[topic] = PartitionCountChange{Old: len(old.partitions), New: len(p)}
 		}
-		k.partitions[topic] = p
 	}
 
-	return nil
+	return res, nil
 }
 
-func (k *Koff) getOffsetCoordinator(consumerGroup string) (*sarama.Broker, error) {
-	if err := k.client.RefreshCoordinator(consumerGroup); err != nil {
-		return nil, err
+// getPartitions returns the partitions of the topics, loading the metadata of those not cached yet or expired in a single request.
+//
+// Returns a map of topics to partitions. Topics which don't exist have no partition.
+func (k *Koff) getPartitions(ctx context.Context, topics ...string) (map[string][]int32, error) {
+	res := make(map[string][]int32)
+
+	var stale []string
+	k.pMu.RLock()
+	for _, topic := range topics {
+		tp, ok := k.partitions[topic]
+		if !ok || (k.metadataTTL > 0 && time.Since(tp.loadedAt) >= k.metadataTTL) {
+			stale = append(stale, topic)
+			continue
+		}
+		res[topic] = tp.partitions
 	}
+	k.pMu.RUnlock()
 
-	offsetCoordinator, err := k.client.Coordinator(consumerGroup)
-	if err != nil {
+	if len(stale) <= 0 {
+		return res, nil
+	}
+
+	if _, err := k.loadTopics(ctx, stale); err != nil {
 		return nil, err
 	}
 
-	if err = offsetCoordinator.Open(nil); err != sarama.ErrAlreadyConnected && err != nil {
-		return offsetCoordinator, nil
+	k.pMu.RLock()
+	defer k.pMu.RUnlock()
+
+	for _, topic := range stale {
+		res[topic] = k.partitions[topic].partitions
 	}
 
-	if _, err = offsetCoordinator.Connected(); err != nil {
+	return res, nil
+}
+
+func (k *Koff) getOffsetCoordinator(ctx context.Context, consumerGroup string) (*sarama.Broker, error) {
+	var offsetCoordinator *sarama.Broker
+	err := withContext(ctx, func() (err error) {
+		if err := k.client.RefreshCoordinator(consumerGroup); err != nil {
+			return err
+		}
+
+		offsetCoordinator, err = k.client.Coordinator(consumerGroup)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	if err := k.connectBroker(ctx, offsetCoordinator); err != nil {
+		return nil, fmt.Errorf("unable to connect to coordinator. err=%v", err)
+	}
+
 	return offsetCoordinator, nil
 }
 
+// RangeVerdict describes where an offset lies compared to the available range of a partition.
+type RangeVerdict int
+
+const (
+	// InRange means the offset is between the oldest retained offset and the log end.
+	InRange RangeVerdict = iota
+	// BelowOldest means the offset is older than the oldest retained offset.
+	BelowOldest
+	// BeyondLogEnd means the offset is past the offset of the next message to be produced.
+	BeyondLogEnd
+)
+
+func (v RangeVerdict) String() string {
+	switch v {
+	case InRange:
+		return "in range"
+	case BelowOldest:
+		return "below oldest"
+	case BeyondLogEnd:
+		return "beyond log end"
+	default:
+		return "unknown"
+	}
+}
+
+// OffsetRange is the result of checking an offset against the available range of a partition.
+//
+// Oldest and Newest are the bounds that were compared, as returned by GetOldestOffsets and GetNewestOffsets.
+type OffsetRange struct {
+	Offset  int64
+	Oldest  int64
+	Newest  int64
+	Verdict RangeVerdict
+}
+
+func newOffsetRange(offset, oldest, newest int64) OffsetRange {
+	r := OffsetRange{
+		Offset: offset,
+		Oldest: oldest,
+		Newest: newest,
+	}
+
+	switch {
+	case offset < oldest:
+		r.Verdict = BelowOldest
+	case offset > newest+1:
+		r.Verdict = BeyondLogEnd
+	default:
+		r.Verdict = InRange
+	}
+
+	return r
+}
+
+// CheckOffsetRange checks the provided offset against the available range of the topic and partitions.
+//
+// The offset right after the newest one is considered in range since it is where a consumer which is caught up is positioned.
+//
+// Returns a map of partitions to range verdict.
+func (k *Koff) CheckOffsetRange(topic string, offset int64, partitions ...int32) (map[int32]OffsetRange, error) {
+	return k.CheckOffsetRangeContext(context.Background(), topic, offset, partitions...)
+}
+
+// CheckOffsetRangeContext is like CheckOffsetRange but honors the deadline and cancellation of ctx.
+func (k *Koff) CheckOffsetRangeContext(ctx context.Context, topic string, offset int64, partitions ...int32) (map[int32]OffsetRange, error) {
+	oldestOffsets, err := k.GetOldestOffsetsContext(ctx, topic, partitions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get oldest offsets. err=%v", err)
+	}
+
+	newestOffsets, err := k.GetNewestOffsetsContext(ctx, topic, partitions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get newest offsets. err=%v", err)
+	}
+
+	res := make(map[int32]OffsetRange)
+	for p, oldest := range oldestOffsets {
+		res[p] = newOffsetRange(offset, oldest, newestOffsets[p])
+	}
+
+	return res, nil
+}
+
 // OffsetInAvailableRange check that the provided offset is in the available range of the topic and partitions.
 //
 // If multiple partitions are provided, the offset is checked for all partitions.
 func (k *Koff) OffsetInAvailableRange(topic string, offset int64, partitions ...int32) (bool, error) {
-	return false, nil
+	return k.OffsetInAvailableRangeContext(context.Background(), topic, offset, partitions...)
 }
 
-func (k *Koff) getOffset(topic string, offset int64, partitions ...int32) (res map[int32]int64, err error) {
-	if len(partitions) <= 0 {
-		k.pMu.RLock()
-		partitions = k.partitions[topic]
-		k.pMu.RUnlock()
+// OffsetInAvailableRangeContext is like OffsetInAvailableRange but honors the deadline and cancellation of ctx.
+func (k *Koff) OffsetInAvailableRangeContext(ctx context.Context, topic string, offset int64, partitions ...int32) (bool, error) {
+	ranges, err := k.CheckOffsetRangeContext(ctx, topic, offset, partitions...)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range ranges {
+		if r.Verdict != InRange {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// PartitionErrors is returned when the data of some partitions of a topic could not be fetched.
+//
+// It maps partitions to the error which occurred. The data of the other partitions is still returned alongside it.
+type PartitionErrors struct {
+	Topic  string
+	Errors map[int32]error
+}
+
+func (e PartitionErrors) Error() string {
+	var partitions []int
+	for p := range e.Errors {
+		partitions = append(partitions, int(p))
+	}
+
+	sort.Ints(partitions)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "unable to get data of %d partition(s) of %q.", len(partitions), e.Topic)
+	for _, p := range partitions {
+		fmt.Fprintf(&buf, " p:%d err=%v", p, e.Errors[int32(p)])
+	}
+
+	return buf.String()
+}
+
+// merge adds the partition errors of err to e, keeping the first error of each partition.
+//
+// Returns err if it is not a PartitionErrors, which means the whole call failed.
+func (e PartitionErrors) merge(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	perr, ok := err.(PartitionErrors)
+	if !ok {
+		return err
+	}
+
+	for p, err := range perr.Errors {
+		if _, ok := e.Errors[p]; !ok {
+			e.Errors[p] = err
+		}
+	}
+
+	return nil
+}
+
+// TopicErrors is returned when the data of some partitions of several topics could not be fetched.
+type TopicErrors map[string]PartitionErrors
+
+func (e TopicErrors) Error() string {
+	var topics []string
+	for topic := range e {
+		topics = append(topics, topic)
 	}
 
-	if len(partitions) > len(k.partitions[topic]) {
-		return nil, fmt.Errorf("topic '%s' has only %d partitions", topic, len(k.partitions[topic]))
+	sort.Strings(topics)
+
+	var errs []string
+	for _, topic := range topics {
+		errs = append(errs, e[topic].Error())
 	}
 
-	res = make(map[int32]int64)
+	return strings.Join(errs, " ")
+}
+
+type leaderPartitions struct {
+	broker     *sarama.Broker
+	partitions []int32
+}
+
+// groupByLeader groups the partitions of a topic by leader so that we can send only one request per broker.
+//
+// Partitions whose leader is unknown are added to errs.
+func (k *Koff) groupByLeader(topic string, partitions []int32, errs PartitionErrors) map[int32]*leaderPartitions {
+	res := make(map[int32]*leaderPartitions)
 	for _, p := range partitions {
-		fetchedOffset, err := k.client.GetOffset(topic, p, offset)
+		broker, err := k.client.Leader(topic, p)
 		if err != nil {
-			return nil, fmt.Errorf("unable to get available offset for (%q, %d) offset %d. err=%v", topic, p, offset, err)
+			errs.Errors[p] = fmt.Errorf("unable to get leader. err=%v", err)
+			continue
 		}
 
-		if offset == sarama.OffsetNewest {
-			// When requesting the newest offset Kafka returns the offset of the NEXT message.
-			// For our purpose we want the most recent offset
-			// https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol#AGuideToTheKafkaProtocol-OffsetRequest
-			res[p] = fetchedOffset - 1
-		} else {
-			res[p] = fetchedOffset
+		l, ok := res[broker.ID()]
+		if !ok {
+			l = &leaderPartitions{broker: broker}
+			res[broker.ID()] = l
 		}
+		l.partitions = append(l.partitions, p)
+	}
+
+	return res
+}
+
+func (k *Koff) getOffset(ctx context.Context, topic string, offset int64, partitions ...int32) (map[int32]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	return
+	loaded, err := k.getPartitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	topicPartitions := loaded[topic]
+
+	if len(partitions) <= 0 {
+		partitions = topicPartitions
+	}
+
+	if len(partitions) > len(topicPartitions) {
+		return nil, fmt.Errorf("topic '%s' has only %d partitions", topic, len(topicPartitions))
+	}
+
+	errs := PartitionErrors{
+		Topic:  topic,
+		Errors: make(map[int32]error),
+	}
+
+	leaders := k.groupByLeader(topic, partitions, errs)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		res = make(map[int32]int64)
+	)
+
+	for _, l := range leaders {
+		wg.Add(1)
+		go func(l *leaderPartitions) {
+			defer wg.Done()
+
+			req := &sarama.OffsetRequest{}
+			if k.client.Config().Version.IsAtLeast(sarama.V0_10_1_0) {
+				req.Version = 1
+			}
+			for _, p := range l.partitions {
+				req.AddBlock(topic, p, offset, 1)
+			}
+
+			var resp *sarama.OffsetResponse
+			err := withContext(ctx, func() (err error) {
+				resp, err = l.broker.GetAvailableOffsets(req)
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for _, p := range l.partitions {
+				if err != nil {
+					errs.Errors[p] = fmt.Errorf("unable to get available offset from broker %d. err=%v", l.broker.ID(), err)
+					continue
+				}
+
+				block := resp.GetBlock(topic, p)
+				switch {
+				case block == nil:
+					errs.Errors[p] = sarama.ErrIncompleteResponse
+					continue
+				case block.Err != sarama.ErrNoError:
+					errs.Errors[p] = block.Err
+					continue
+				case offset >= 0 && (len(block.Offsets) == 0 || block.Offsets[0] < 0):
+					// Kafka returns -1 when there is no message at or after the requested timestamp.
+					errs.Errors[p] = ErrNoOffsetForTime
+					continue
+				case len(block.Offsets) != 1:
+					errs.Errors[p] = sarama.ErrOffsetOutOfRange
+					continue
+				}
+
+				if offset == sarama.OffsetNewest {
+					// When requesting the newest offset Kafka returns the offset of the NEXT message.
+					// For our purpose we want the most recent offset
+					// https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol#AGuideToTheKafkaProtocol-OffsetRequest
+					res[p] = block.Offsets[0] - 1
+				} else {
+					res[p] = block.Offsets[0]
+				}
+			}
+		}(l)
+	}
+
+	wg.Wait()
+
+	if len(errs.Errors) > 0 {
+		return res, errs
+	}
+
+	return res, nil
 }
 
 // GetOldestOffsets retrieves the oldest available offsets for each partitions of the provided topic.
 //
-// Returns a map of partitions to offset.
-func (k *Koff) GetOldestOffsets(topic string, partitions ...int32) (res map[int32]int64, err error) {
-	return k.getOffset(topic, sarama.OffsetOldest, partitions...)
+// Returns a map of partitions to offset. If some partitions failed, the offsets of the others are returned along with a PartitionErrors.
+func (k *Koff) GetOldestOffsets(topic string, partitions ...int32) (map[int32]int64, error) {
+	return k.GetOldestOffsetsContext(context.Background(), topic, partitions...)
+}
+
+// GetOldestOffsetsContext is like GetOldestOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) GetOldestOffsetsContext(ctx context.Context, topic string, partitions ...int32) (map[int32]int64, error) {
+	return k.getOffset(ctx, topic, sarama.OffsetOldest, partitions...)
 }
 
 // GetNewestOffsets retrieves the newest available offsets for each partitions of the provided topic.
 //
-// Returns a map of partitions to offset.
+// Returns a map of partitions to offset. If some partitions failed, the offsets of the others are returned along with a PartitionErrors.
 func (k *Koff) GetNewestOffsets(topic string, partitions ...int32) (map[int32]int64, error) {
-	return k.getOffset(topic, sarama.OffsetNewest, partitions...)
+	return k.GetNewestOffsetsContext(context.Background(), topic, partitions...)
+}
+
+// GetNewestOffsetsContext is like GetNewestOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) GetNewestOffsetsContext(ctx context.Context, topic string, partitions ...int32) (map[int32]int64, error) {
+	return k.getOffset(ctx, topic, sarama.OffsetNewest, partitions...)
+}
+
+// ErrNoOffsetForTime is returned for partitions with no message at or after the time given to GetOffsetsForTime.
+var ErrNoOffsetForTime = errors.New("no offset at or after the given time")
+
+// GetOffsetsForTime retrieves, for each partitions of the provided topic, the offset of the first message with a timestamp at or after t.
+//
+// It needs the client to be configured for Kafka 0.10.1 or newer, the older versions of the protocol do not support message timestamps.
+//
+// Returns a map of partitions to offset. Partitions without such a message are reported in a PartitionErrors with ErrNoOffsetForTime.
+func (k *Koff) GetOffsetsForTime(topic string, t time.Time, partitions ...int32) (map[int32]int64, error) {
+	return k.GetOffsetsForTimeContext(context.Background(), topic, t, partitions...)
+}
+
+// GetOffsetsForTimeContext is like GetOffsetsForTime but honors the deadline and cancellation of ctx.
+func (k *Koff) GetOffsetsForTimeContext(ctx context.Context, topic string, t time.Time, partitions ...int32) (map[int32]int64, error) {
+	if !k.client.Config().Version.IsAtLeast(sarama.V0_10_1_0) {
+		return nil, errors.New("offset lookup by time needs Kafka 0.10.1 or newer")
+	}
+
+	return k.getOffset(ctx, topic, t.UnixNano()/int64(time.Millisecond), partitions...)
 }
 
 type OffsetVersion int16
@@ -158,18 +648,31 @@ func (v OffsetVersion) String() string {
 	}
 }
 
-// GetConsumerGroupOffsets retrieves the last committed offsets for the given consumer group.
-// Returns a map of partitions to offset.
-func (k *Koff) GetConsumerGroupOffsets(consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]int64, error) {
-	offsetCoordinator, err := k.getOffsetCoordinator(consumerGroup)
+// CommittedOffset is an offset committed by a consumer group along with its metadata.
+type CommittedOffset struct {
+	Offset   int64
+	Metadata string
+}
+
+// GetCommittedOffsets retrieves the last committed offsets for the given consumer group along with their metadata.
+// Returns a map of partitions to committed offset. If some partitions failed, the offsets of the others are returned along with a PartitionErrors.
+func (k *Koff) GetCommittedOffsets(consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]CommittedOffset, error) {
+	return k.GetCommittedOffsetsContext(context.Background(), consumerGroup, topic, version, partitions...)
+}
+
+// GetCommittedOffsetsContext is like GetCommittedOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) GetCommittedOffsetsContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]CommittedOffset, error) {
+	offsetCoordinator, err := k.getOffsetCoordinator(ctx, consumerGroup)
 	if err != nil {
 		return nil, fmt.Errorf("unable to init offset coordinator. err=%v", err)
 	}
 
 	if len(partitions) <= 0 {
-		k.pMu.RLock()
-		partitions = k.partitions[topic]
-		k.pMu.RUnlock()
+		loaded, err := k.getPartitions(ctx, topic)
+		if err != nil {
+			return nil, err
+		}
+		partitions = loaded[topic]
 	}
 
 	req := &sarama.OffsetFetchRequest{
@@ -180,42 +683,250 @@ func (k *Koff) GetConsumerGroupOffsets(consumerGroup, topic string, version Offs
 		req.AddPartition(topic, p)
 	}
 
-	resp, err := offsetCoordinator.FetchOffset(req)
+	var resp *sarama.OffsetFetchResponse
+	err = withContext(ctx, func() (err error) {
+		resp, err = offsetCoordinator.FetchOffset(req)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to fetch offset of (%s, %d). err=%v", topic, version, err)
 	}
 
-	res := make(map[int32]int64)
+	errs := PartitionErrors{
+		Topic:  topic,
+		Errors: make(map[int32]error),
+	}
+
+	res := make(map[int32]CommittedOffset)
 	for _, p := range partitions {
-		block := resp.Blocks[topic][p]
-		if block.Err != sarama.ErrNoError {
-			return nil, fmt.Errorf("unable to fetch offset of (%s, %d). err=%v", topic, version, block.Err)
+		block := resp.GetBlock(topic, p)
+		switch {
+		case block == nil:
+			errs.Errors[p] = sarama.ErrIncompleteResponse
+			continue
+		case block.Err != sarama.ErrNoError:
+			errs.Errors[p] = block.Err
+			continue
 		}
 
-		res[p] = block.Offset
+		res[p] = CommittedOffset{
+			Offset:   block.Offset,
+			Metadata: block.Metadata,
+		}
+	}
+
+	if len(errs.Errors) > 0 {
+		return res, errs
 	}
 
 	return res, nil
 }
 
+// GetConsumerGroupOffsets retrieves the last committed offsets for the given consumer group.
+// Returns a map of partitions to offset. If some partitions failed, the offsets of the others are returned along with a PartitionErrors.
+func (k *Koff) GetConsumerGroupOffsets(consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]int64, error) {
+	return k.GetConsumerGroupOffsetsContext(context.Background(), consumerGroup, topic, version, partitions...)
+}
+
+// GetConsumerGroupOffsetsContext is like GetConsumerGroupOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) GetConsumerGroupOffsetsContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]int64, error) {
+	committed, err := k.GetCommittedOffsetsContext(ctx, consumerGroup, topic, version, partitions...)
+	if committed == nil {
+		return nil, err
+	}
+
+	res := make(map[int32]int64)
+	for p, c := range committed {
+		res[p] = c.Offset
+	}
+
+	return res, err
+}
+
+// GetConsumerGroupOffsetsByTopic retrieves the last committed offsets of the consumer group on the given topics in a single request.
+//
+// If no topic is provided, every topic known by the Koff instance is used.
+// Partitions on which the consumer group never committed are left out, as well as topics without any committed offset.
+//
+// Returns a map of topics to partitions to offset. If some partitions failed, the offsets of the others are returned along with a TopicErrors.
+func (k *Koff) GetConsumerGroupOffsetsByTopic(consumerGroup string, version OffsetVersion, topics ...string) (map[string]map[int32]int64, error) {
+	return k.GetConsumerGroupOffsetsByTopicContext(context.Background(), consumerGroup, version, topics...)
+}
+
+// GetConsumerGroupOffsetsByTopicContext is like GetConsumerGroupOffsetsByTopic but honors the deadline and cancellation of ctx.
+func (k *Koff) GetConsumerGroupOffsetsByTopicContext(ctx context.Context, consumerGroup string, version OffsetVersion, topics ...string) (map[string]map[int32]int64, error) {
+	offsetCoordinator, err := k.getOffsetCoordinator(ctx, consumerGroup)
+	if err != nil {
+		return nil, fmt.Errorf("unable to init offset coordinator. err=%v", err)
+	}
+
+	if len(topics) <= 0 {
+		topics = k.Topics()
+	}
+
+	req := &sarama.OffsetFetchRequest{
+		ConsumerGroup: consumerGroup,
+		Version:       int16(version),
+	}
+
+	loaded, err := k.getPartitions(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range topics {
+		for _, p := range loaded[topic] {
+			req.AddPartition(topic, p)
+		}
+	}
+
+	var resp *sarama.OffsetFetchResponse
+	err = withContext(ctx, func() (err error) {
+		resp, err = offsetCoordinator.FetchOffset(req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch offsets of %q. err=%v", consumerGroup, err)
+	}
+
+	errs := make(TopicErrors)
+
+	res := make(map[string]map[int32]int64)
+	for topic, blocks := range resp.Blocks {
+		for p, block := range blocks {
+			if block.Err != sarama.ErrNoError {
+				if _, ok := errs[topic]; !ok {
+					errs[topic] = PartitionErrors{Topic: topic, Errors: make(map[int32]error)}
+				}
+				errs[topic].Errors[p] = block.Err
+				continue
+			}
+
+			if block.Offset < 0 {
+				continue
+			}
+
+			if res[topic] == nil {
+				res[topic] = make(map[int32]int64)
+			}
+			res[topic][p] = block.Offset
+		}
+	}
+
+	if len(errs) > 0 {
+		return res, errs
+	}
+
+	return res, nil
+}
+
+// CommitConsumerGroupOffsets commits the given offsets for the consumer group.
+//
+// The offsets are committed as-is, it is up to the caller to make sure nothing is consuming with this consumer group.
+// If some partitions failed, a PartitionErrors is returned.
+func (k *Koff) CommitConsumerGroupOffsets(consumerGroup, topic string, version OffsetVersion, offsets map[int32]int64) error {
+	return k.CommitConsumerGroupOffsetsContext(context.Background(), consumerGroup, topic, version, offsets)
+}
+
+// CommitConsumerGroupOffsetsContext is like CommitConsumerGroupOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) CommitConsumerGroupOffsetsContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, offsets map[int32]int64) error {
+	committed := make(map[int32]CommittedOffset)
+	for p, offset := range offsets {
+		committed[p] = CommittedOffset{Offset: offset}
+	}
+
+	return k.CommitOffsetsContext(ctx, consumerGroup, topic, version, committed)
+}
+
+// CommitOffsets commits the given offsets and their metadata for the consumer group.
+//
+// Like CommitConsumerGroupOffsets the offsets are committed as-is.
+// If some partitions failed, a PartitionErrors is returned.
+func (k *Koff) CommitOffsets(consumerGroup, topic string, version OffsetVersion, offsets map[int32]CommittedOffset) error {
+	return k.CommitOffsetsContext(context.Background(), consumerGroup, topic, version, offsets)
+}
+
+// CommitOffsetsContext is like CommitOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) CommitOffsetsContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, offsets map[int32]CommittedOffset) error {
+	offsetCoordinator, err := k.getOffsetCoordinator(ctx, consumerGroup)
+	if err != nil {
+		return fmt.Errorf("unable to init offset coordinator. err=%v", err)
+	}
+
+	req := &sarama.OffsetCommitRequest{
+		ConsumerGroup:           consumerGroup,
+		ConsumerGroupGeneration: sarama.GroupGenerationUndefined,
+		Version:                 int16(version),
+	}
+	for p, c := range offsets {
+		req.AddBlock(topic, p, c.Offset, sarama.ReceiveTime, c.Metadata)
+	}
+
+	var resp *sarama.OffsetCommitResponse
+	err = withContext(ctx, func() (err error) {
+		resp, err = offsetCoordinator.CommitOffset(req)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to commit offsets of %q. err=%v", topic, err)
+	}
+
+	errs := PartitionErrors{
+		Topic:  topic,
+		Errors: make(map[int32]error),
+	}
+	for p := range offsets {
+		kerr, ok := resp.Errors[topic][p]
+		switch {
+		case !ok:
+			errs.Errors[p] = sarama.ErrIncompleteResponse
+		case kerr != sarama.ErrNoError:
+			errs.Errors[p] = kerr
+		}
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
 // GetDrift computes the drift between the last comitted offsets of a consumer group and the newest offsets available for a topic and partition.
 //
-// Returns a map of partitions to offset.
+// Returns a map of partitions to offset. If some partitions failed, the drift of the others are returned along with a PartitionErrors.
 func (k *Koff) GetDrift(consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]int64, error) {
-	availableOffsets, err := k.GetNewestOffsets(topic, partitions...)
-	if err != nil {
+	return k.GetDriftContext(context.Background(), consumerGroup, topic, version, partitions...)
+}
+
+// GetDriftContext is like GetDrift but honors the deadline and cancellation of ctx.
+func (k *Koff) GetDriftContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]int64, error) {
+	errs := PartitionErrors{
+		Topic:  topic,
+		Errors: make(map[int32]error),
+	}
+
+	availableOffsets, err := k.GetNewestOffsetsContext(ctx, topic, partitions...)
+	if err := errs.merge(err); err != nil {
 		return nil, fmt.Errorf("unable to get newest offsets. err=%v", err)
 	}
 
-	cgroupOffsets, err := k.GetConsumerGroupOffsets(consumerGroup, topic, version, partitions...)
-	if err != nil {
+	cgroupOffsets, err := k.GetConsumerGroupOffsetsContext(ctx, consumerGroup, topic, version, partitions...)
+	if err := errs.merge(err); err != nil {
 		return nil, fmt.Errorf("unable to get consumer group offsets. err=%v", err)
 	}
 
 	res := make(map[int32]int64)
 	for k, v := range cgroupOffsets {
+		if _, ok := errs.Errors[k]; ok {
+			continue
+		}
 		res[k] = availableOffsets[k] - v
 	}
 
+	if len(errs.Errors) > 0 {
+		return res, errs
+	}
+
 	return res, nil
 }
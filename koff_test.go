@@ -1,7 +1,10 @@
 package koff_test
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/require"
@@ -35,9 +38,40 @@ func getClient(t testing.TB) (sarama.Client, func()) {
 	offsetFetchResponse := sarama.NewMockOffsetFetchResponse(t)
 	offsetFetchResponse.SetOffset("myConsumerGroup", "foobar", 0, 800, "", sarama.ErrNoError)
 	offsetFetchResponse.SetOffset("myConsumerGroup", "foobar", 1, 8000, "", sarama.ErrNoError)
+	offsetFetchResponse.SetOffset("myNewGroup", "foobar", 0, -1, "", sarama.ErrNoError)
+	offsetFetchResponse.SetOffset("myNewGroup", "foobar", 1, -1, "", sarama.ErrNoError)
+	offsetFetchResponse.SetOffset("myBrokenGroup", "foobar", 0, 900, "", sarama.ErrNoError)
+	offsetFetchResponse.SetOffset("myBrokenGroup", "foobar", 1, -1, "", sarama.ErrOffsetsLoadInProgress)
 
 	consumerMetadataResponse := sarama.NewMockConsumerMetadataResponse(t)
 	consumerMetadataResponse.SetCoordinator("myConsumerGroup", broker)
+	consumerMetadataResponse.SetCoordinator("myNewGroup", broker)
+	consumerMetadataResponse.SetCoordinator("myBrokenGroup", broker)
+
+	describeGroupsResponse := &sarama.DescribeGroupsResponse{
+		Groups: []*sarama.GroupDescription{
+			{
+				GroupId:      "myConsumerGroup",
+				State:        "Stable",
+				ProtocolType: "consumer",
+				Protocol:     "range",
+				Members: map[string]*sarama.GroupMemberDescription{
+					"consumer-2-b": {
+						ClientId:         "consumer-2",
+						ClientHost:       "/10.0.0.2",
+						MemberMetadata:   encodeMemberMetadata("foobar"),
+						MemberAssignment: encodeMemberAssignment("foobar", 1),
+					},
+					"consumer-1-a": {
+						ClientId:         "consumer-1",
+						ClientHost:       "/10.0.0.1",
+						MemberMetadata:   encodeMemberMetadata("foobar"),
+						MemberAssignment: encodeMemberAssignment("foobar", 0),
+					},
+				},
+			},
+		},
+	}
 
 	broker.SetHandlerByMap(map[string]sarama.MockResponse{
 		"MetadataRequest":         metadataResponse,
@@ -46,10 +80,13 @@ func getClient(t testing.TB) (sarama.Client, func()) {
 		"OffsetRequest":           offsetResponse,
 		"OffsetFetchRequest":      offsetFetchResponse,
 		"ConsumerMetadataRequest": consumerMetadataResponse,
+		"DescribeGroupsRequest":   sarama.NewMockWrapper(describeGroupsResponse),
+		"OffsetCommitRequest":     sarama.NewMockOffsetCommitResponse(t),
 	})
 
 	config := sarama.NewConfig()
 	config.Producer.Partitioner = sarama.NewManualPartitioner
+	config.Version = sarama.V0_9_0_0
 
 	client, err := sarama.NewClient([]string{broker.Addr()}, config)
 	require.Nil(t, err)
@@ -115,3 +152,410 @@ func TestGetDrift(t *testing.T) {
 	require.Equal(t, int64(199), drifts[0])
 	require.Equal(t, int64(1999), drifts[1])
 }
+
+func TestCheckOffsetRange(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	ranges, err := k.CheckOffsetRange("foobar", 1000, 0, 1)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(ranges))
+
+	require.Equal(t, koff.InRange, ranges[0].Verdict)
+	require.Equal(t, int64(500), ranges[0].Oldest)
+	require.Equal(t, int64(999), ranges[0].Newest)
+
+	require.Equal(t, koff.BelowOldest, ranges[1].Verdict)
+	require.Equal(t, int64(5000), ranges[1].Oldest)
+	require.Equal(t, int64(9999), ranges[1].Newest)
+
+	ranges, err = k.CheckOffsetRange("foobar", 1001, 0)
+	require.Nil(t, err)
+	require.Equal(t, koff.BeyondLogEnd, ranges[0].Verdict)
+}
+
+func TestOffsetInAvailableRange(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	ok, err := k.OffsetInAvailableRange("foobar", 600, 0)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = k.OffsetInAvailableRange("foobar", 600, 0, 1)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = k.OffsetInAvailableRange("foobar", 6000, 1)
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+func getMultiBrokerClient(t testing.TB) (sarama.Client, []*sarama.MockBroker, func()) {
+	seedBroker := sarama.NewMockBroker(t, 1)
+	leader1 := sarama.NewMockBroker(t, 2)
+	leader2 := sarama.NewMockBroker(t, 3)
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(leader1.Addr(), leader1.BrokerID())
+	metadataResponse.SetBroker(leader2.Addr(), leader2.BrokerID())
+	metadataResponse.SetLeader("foobar", 0, leader1.BrokerID())
+	metadataResponse.SetLeader("foobar", 1, leader2.BrokerID())
+	metadataResponse.SetLeader("foobar", 2, leader2.BrokerID())
+	metadataResponse.SetLeader("foobar", 3, leader1.BrokerID())
+
+	seedBroker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadataResponse,
+	})
+
+	offsetResponse1 := sarama.NewMockOffsetResponse(t)
+	offsetResponse1.SetOffset("foobar", 0, sarama.OffsetOldest, 100)
+	offsetResponse1.SetOffset("foobar", 0, sarama.OffsetNewest, 200)
+	offsetResponse1.SetOffset("foobar", 3, sarama.OffsetOldest, 400)
+	offsetResponse1.SetOffset("foobar", 3, sarama.OffsetNewest, 500)
+
+	leader1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadataResponse,
+		"OffsetRequest":   offsetResponse1,
+	})
+
+	offsetResponse2 := &sarama.OffsetResponse{}
+	offsetResponse2.AddTopicPartition("foobar", 1, 300)
+	offsetResponse2.AddTopicPartition("foobar", 2, 0)
+	offsetResponse2.Blocks["foobar"][2].Err = sarama.ErrNotLeaderForPartition
+
+	leader2.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadataResponse,
+		"OffsetRequest":   sarama.NewMockWrapper(offsetResponse2),
+	})
+
+	client, err := sarama.NewClient([]string{seedBroker.Addr()}, sarama.NewConfig())
+	require.Nil(t, err)
+
+	closeFn := func() {
+		require.Nil(t, client.Close())
+		seedBroker.Close()
+		leader1.Close()
+		leader2.Close()
+	}
+
+	return client, []*sarama.MockBroker{leader1, leader2}, closeFn
+}
+
+func countRequests(broker *sarama.MockBroker, typ interface{}) (n int) {
+	for _, rr := range broker.History() {
+		if reflect.TypeOf(rr.Request) == reflect.TypeOf(typ) {
+			n++
+		}
+	}
+	return
+}
+
+func TestGetOffsetsMultipleBrokers(t *testing.T) {
+	client, brokers, closeFn := getMultiBrokerClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	offsets, err := k.GetOldestOffsets("foobar", 0, 1, 3)
+	require.Nil(t, err)
+
+	require.Equal(t, 3, len(offsets))
+	require.Equal(t, int64(100), offsets[0])
+	require.Equal(t, int64(300), offsets[1])
+	require.Equal(t, int64(400), offsets[3])
+
+	// One request per leader, regardless of the number of partitions it leads.
+	require.Equal(t, 1, countRequests(brokers[0], &sarama.OffsetRequest{}))
+	require.Equal(t, 1, countRequests(brokers[1], &sarama.OffsetRequest{}))
+}
+
+func TestGetOffsetsPartitionErrors(t *testing.T) {
+	client, _, closeFn := getMultiBrokerClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	offsets, err := k.GetNewestOffsets("foobar")
+	require.NotNil(t, err)
+
+	require.Equal(t, 3, len(offsets))
+	require.Equal(t, int64(199), offsets[0])
+	require.Equal(t, int64(299), offsets[1])
+	require.Equal(t, int64(499), offsets[3])
+
+	perr, ok := err.(koff.PartitionErrors)
+	require.True(t, ok)
+	require.Equal(t, "foobar", perr.Topic)
+	require.Equal(t, 1, len(perr.Errors))
+	require.Equal(t, sarama.ErrNotLeaderForPartition, perr.Errors[2])
+}
+
+func TestGetOffsetsForTime(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker.Addr(), 1)
+	metadataResponse.SetLeader("foobar", 0, 1)
+	metadataResponse.SetLeader("foobar", 1, 1)
+
+	offsetResponse := &sarama.OffsetResponse{Version: 1}
+	offsetResponse.AddTopicPartition("foobar", 0, 700)
+	offsetResponse.AddTopicPartition("foobar", 1, -1)
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadataResponse,
+		"OffsetRequest":   sarama.NewMockWrapper(offsetResponse),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_1_0
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+
+	offsets, err := k.GetOffsetsForTime("foobar", time.Now().Add(-time.Hour))
+	require.NotNil(t, err)
+
+	require.Equal(t, 1, len(offsets))
+	require.Equal(t, int64(700), offsets[0])
+
+	perr, ok := err.(koff.PartitionErrors)
+	require.True(t, ok)
+	require.Equal(t, koff.ErrNoOffsetForTime, perr.Errors[1])
+
+	var req *sarama.OffsetRequest
+	for _, rr := range broker.History() {
+		if r, ok := rr.Request.(*sarama.OffsetRequest); ok {
+			req = r
+		}
+	}
+	require.NotNil(t, req)
+	require.Equal(t, int16(1), req.Version)
+}
+
+func TestGetOffsetsForTimeOldVersion(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	_, err = k.GetOffsetsForTime("foobar", time.Now())
+	require.NotNil(t, err)
+}
+
+func TestTopics(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	require.Equal(t, []string{"foobar"}, k.Topics())
+}
+
+func TestGetConsumerGroupOffsetsByTopic(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	offsets, err := k.GetConsumerGroupOffsetsByTopic("myConsumerGroup", koff.KafkaOffsetVersion)
+	require.Nil(t, err)
+	require.Equal(t, map[string]map[int32]int64{"foobar": {0: 800, 1: 8000}}, offsets)
+
+	offsets, err = k.GetConsumerGroupOffsetsByTopic("myNewGroup", koff.KafkaOffsetVersion)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(offsets))
+}
+
+func TestGetConsumerGroupOffsetsPartitionErrors(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	offsets, err := k.GetConsumerGroupOffsets("myBrokenGroup", "foobar", koff.KafkaOffsetVersion)
+	require.NotNil(t, err)
+	require.Equal(t, map[int32]int64{0: 900}, offsets)
+
+	perr, ok := err.(koff.PartitionErrors)
+	require.True(t, ok)
+	require.Equal(t, "foobar", perr.Topic)
+	require.Equal(t, sarama.ErrOffsetsLoadInProgress, perr.Errors[1])
+
+	drift, err := k.GetDrift("myBrokenGroup", "foobar", koff.KafkaOffsetVersion)
+	require.NotNil(t, err)
+	require.Equal(t, map[int32]int64{0: 99}, drift)
+
+	perr, ok = err.(koff.PartitionErrors)
+	require.True(t, ok)
+	require.Equal(t, 1, len(perr.Errors))
+
+	byTopic, err := k.GetConsumerGroupOffsetsByTopic("myBrokenGroup", koff.KafkaOffsetVersion)
+	require.NotNil(t, err)
+	require.Equal(t, map[string]map[int32]int64{"foobar": {0: 900}}, byTopic)
+
+	terr, ok := err.(koff.TopicErrors)
+	require.True(t, ok)
+	require.Equal(t, sarama.ErrOffsetsLoadInProgress, terr["foobar"].Errors[1])
+}
+
+func TestContextCanceled(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = k.GetNewestOffsetsContext(ctx, "foobar", 0, 1)
+	require.Equal(t, context.Canceled, err)
+
+	_, err = k.GetDriftContext(ctx, "myConsumerGroup", "foobar", koff.KafkaOffsetVersion, 0, 1)
+	require.NotNil(t, err)
+
+	err = k.InitContext(ctx)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestRefresh(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker.Addr(), 1)
+	metadataResponse.SetLeader("foobar", 0, 1)
+	metadataResponse.SetLeader("foobar", 1, 1)
+
+	offsetResponse := sarama.NewMockOffsetResponse(t)
+	for p := int32(0); p < 3; p++ {
+		offsetResponse.SetOffset("foobar", p, sarama.OffsetNewest, 1000)
+	}
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadataResponse,
+		"OffsetRequest":   offsetResponse,
+	})
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, sarama.NewConfig())
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+
+	offsets, err := k.GetNewestOffsets("foobar")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(offsets))
+
+	// The topic is expanded, the cached metadata is still used until it expires or is refreshed.
+	metadataResponse.SetLeader("foobar", 2, 1)
+
+	offsets, err = k.GetNewestOffsets("foobar")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(offsets))
+
+	changes, err := k.Refresh()
+	require.Nil(t, err)
+	require.Equal(t, map[string]koff.PartitionCountChange{"foobar": {Old: 2, New: 3}}, changes)
+
+	offsets, err = k.GetNewestOffsets("foobar")
+	require.Nil(t, err)
+	require.Equal(t, 3, len(offsets))
+
+	changes, err = k.Refresh("foobar")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(changes))
+
+	// Once the TTL expired, the metadata is loaded again on the next use.
+	metadataResponse.SetLeader("foobar", 3, 1)
+	offsetResponse.SetOffset("foobar", 3, sarama.OffsetNewest, 1000)
+	k.SetMetadataTTL(time.Nanosecond)
+
+	offsets, err = k.GetNewestOffsets("foobar")
+	require.Nil(t, err)
+	require.Equal(t, 4, len(offsets))
+}
+
+func TestRefreshDeletedTopic(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := &sarama.MetadataResponse{}
+	metadataResponse.AddBroker(broker.Addr(), 1)
+	metadataResponse.AddTopicPartition("foobar", 0, 1, []int32{1}, []int32{1}, sarama.ErrNoError)
+	metadataResponse.AddTopicPartition("deleted", 0, 1, []int32{1}, []int32{1}, sarama.ErrNoError)
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockWrapper(metadataResponse),
+	})
+
+	config := sarama.NewConfig()
+	config.Metadata.Retry.Max = 0
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+	require.Equal(t, []string{"deleted", "foobar"}, k.Topics())
+
+	partitions, err := k.Partitions("deleted")
+	require.Nil(t, err)
+	require.Equal(t, []int32{0}, partitions)
+
+	// The topic is deleted, Kafka reports it as unknown.
+	metadataResponse = &sarama.MetadataResponse{}
+	metadataResponse.AddBroker(broker.Addr(), 1)
+	metadataResponse.AddTopicPartition("foobar", 0, 1, []int32{1}, []int32{1}, sarama.ErrNoError)
+	metadataResponse.AddTopic("deleted", sarama.ErrUnknownTopicOrPartition)
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockWrapper(metadataResponse),
+	})
+
+	changes, err := k.Refresh()
+	require.Nil(t, err)
+	require.Equal(t, map[string]koff.PartitionCountChange{"deleted": {Old: 1, New: 0}}, changes)
+	require.Equal(t, []string{"foobar"}, k.Topics())
+
+	changes, err = k.Refresh()
+	require.Nil(t, err)
+	require.Equal(t, 0, len(changes))
+
+	partitions, err = k.Partitions("foobar")
+	require.Nil(t, err)
+	require.Equal(t, []int32{0}, partitions)
+}
@@ -0,0 +1,315 @@
+package koff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Lag returns the number of messages after the committed offset of a partition, newest being the offset of its last message
+// as returned by GetNewestOffsets. It is clamped at 0 for committed offsets beyond the end of the log.
+//
+// ok is false if the consumer group never committed an offset on the partition.
+func Lag(newest, committed int64) (lag int64, ok bool) {
+	if committed < 0 {
+		return 0, false
+	}
+
+	lag = newest + 1 - committed
+	if lag < 0 {
+		lag = 0
+	}
+
+	return lag, true
+}
+
+// PartitionLag is the lag of a consumer group on a partition.
+type PartitionLag struct {
+	Newest    int64
+	Committed int64
+	// Drift is the lag computed by Lag. It is 0 if the consumer group never committed an offset on the partition.
+	Drift int64
+
+	// Owner is the member of the consumer group currently assigned to the partition.
+	// It is nil if the partition is unassigned or if the owner is unknown.
+	Owner *GroupMember
+	// OwnerUnknown is true if the consumer group could not be described, like on brokers older than Kafka 0.9
+	// or for groups committing their offsets to ZooKeeper which have no coordinator membership.
+	OwnerUnknown bool
+}
+
+// GetLagReport computes the lag of a consumer group from the end of the log and joins each partition with the member currently assigned to it.
+//
+// If the consumer group can't be described the lag is still reported, with OwnerUnknown set.
+//
+// Returns a map of partitions to lag. If some partitions failed, the lag of the others are returned along with a PartitionErrors.
+func (k *Koff) GetLagReport(consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]PartitionLag, error) {
+	return k.GetLagReportContext(context.Background(), consumerGroup, topic, version, partitions...)
+}
+
+// GetLagReportContext is like GetLagReport but honors the deadline and cancellation of ctx.
+func (k *Koff) GetLagReportContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]PartitionLag, error) {
+	errs := PartitionErrors{
+		Topic:  topic,
+		Errors: make(map[int32]error),
+	}
+
+	availableOffsets, err := k.GetNewestOffsetsContext(ctx, topic, partitions...)
+	if err := errs.merge(err); err != nil {
+		return nil, fmt.Errorf("unable to get newest offsets. err=%v", err)
+	}
+
+	cgroupOffsets, err := k.GetConsumerGroupOffsetsContext(ctx, consumerGroup, topic, version, partitions...)
+	if err := errs.merge(err); err != nil {
+		return nil, fmt.Errorf("unable to get consumer group offsets. err=%v", err)
+	}
+
+	owners := make(map[int32]*GroupMember)
+
+	desc, err := k.DescribeConsumerGroupContext(ctx, consumerGroup)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ownerUnknown := err != nil
+	if err == nil {
+		for i := range desc.Members {
+			m := &desc.Members[i]
+			for _, p := range m.Assignment[topic] {
+				owners[p] = m
+			}
+		}
+	}
+
+	res := make(map[int32]PartitionLag)
+	for p, committed := range cgroupOffsets {
+		if _, ok := errs.Errors[p]; ok {
+			continue
+		}
+
+		drift, _ := Lag(availableOffsets[p], committed)
+
+		res[p] = PartitionLag{
+			Newest:       availableOffsets[p],
+			Committed:    committed,
+			Drift:        drift,
+			Owner:        owners[p],
+			OwnerUnknown: ownerUnknown,
+		}
+	}
+
+	if len(errs.Errors) > 0 {
+		return res, errs
+	}
+
+	return res, nil
+}
+
+// ErrNoCommittedOffset is returned for partitions on which a consumer group never committed an offset.
+var ErrNoCommittedOffset = errors.New("no committed offset")
+
+// PartitionTimeLag is the lag of a consumer group on a partition expressed as a duration.
+type PartitionTimeLag struct {
+	Newest    int64
+	Committed int64
+
+	// NewestTimestamp is the timestamp of the newest message.
+	NewestTimestamp time.Time
+	// CommittedTimestamp is the timestamp of the message at the committed offset.
+	// It is zero if the consumer group is caught up.
+	CommittedTimestamp time.Time
+
+	// Lag is how far behind the newest message the consumer group is.
+	Lag time.Duration
+}
+
+// GetTimeLag computes the lag of a consumer group as the difference between the timestamp of the newest message and the one of the message at the committed offset.
+//
+// It needs the client to be configured for Kafka 0.10 or newer, the older messages do not have a timestamp.
+//
+// Returns a map of partitions to lag. If some partitions failed, the lag of the others are returned along with a PartitionErrors.
+func (k *Koff) GetTimeLag(consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]PartitionTimeLag, error) {
+	return k.GetTimeLagContext(context.Background(), consumerGroup, topic, version, partitions...)
+}
+
+// GetTimeLagContext is like GetTimeLag but honors the deadline and cancellation of ctx.
+func (k *Koff) GetTimeLagContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, partitions ...int32) (map[int32]PartitionTimeLag, error) {
+	if !k.client.Config().Version.IsAtLeast(sarama.V0_10_0_0) {
+		return nil, errors.New("message timestamps need Kafka 0.10 or newer")
+	}
+
+	errs := PartitionErrors{
+		Topic:  topic,
+		Errors: make(map[int32]error),
+	}
+
+	availableOffsets, err := k.GetNewestOffsetsContext(ctx, topic, partitions...)
+	if err := errs.merge(err); err != nil {
+		return nil, fmt.Errorf("unable to get newest offsets. err=%v", err)
+	}
+
+	cgroupOffsets, err := k.GetConsumerGroupOffsetsContext(ctx, consumerGroup, topic, version, partitions...)
+	if err := errs.merge(err); err != nil {
+		return nil, fmt.Errorf("unable to get consumer group offsets. err=%v", err)
+	}
+
+	res := make(map[int32]PartitionTimeLag)
+	toFetch := make(map[int32]int64)
+	for p, committed := range cgroupOffsets {
+		newest := availableOffsets[p]
+
+		switch {
+		case errs.Errors[p] != nil:
+			continue
+		case committed < 0:
+			errs.Errors[p] = ErrNoCommittedOffset
+			continue
+		case committed <= newest:
+			toFetch[p] = committed
+		}
+
+		res[p] = PartitionTimeLag{
+			Newest:    newest,
+			Committed: committed,
+		}
+	}
+
+	newestToFetch := make(map[int32]int64)
+	for p := range res {
+		if res[p].Newest >= 0 {
+			newestToFetch[p] = res[p].Newest
+		}
+	}
+
+	committedTimestamps := k.getMessageTimestamps(ctx, topic, toFetch, errs)
+	newestTimestamps := k.getMessageTimestamps(ctx, topic, newestToFetch, errs)
+
+	for p, l := range res {
+		if _, ok := errs.Errors[p]; ok {
+			delete(res, p)
+			continue
+		}
+
+		l.NewestTimestamp = newestTimestamps[p]
+		if ts, ok := committedTimestamps[p]; ok {
+			l.CommittedTimestamp = ts
+			l.Lag = l.NewestTimestamp.Sub(ts)
+			if l.Lag < 0 {
+				// Timestamps set by producers are not guaranteed to be monotonic.
+				l.Lag = 0
+			}
+		}
+
+		res[p] = l
+	}
+
+	if len(errs.Errors) > 0 {
+		return res, errs
+	}
+
+	return res, nil
+}
+
+// getMessageTimestamps fetches the messages at the given offsets and returns their timestamps.
+//
+// Returns a map of partitions to timestamp. Partitions which failed are added to errs.
+func (k *Koff) getMessageTimestamps(ctx context.Context, topic string, offsets map[int32]int64, errs PartitionErrors) map[int32]time.Time {
+	var partitions []int32
+	for p := range offsets {
+		partitions = append(partitions, p)
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		res     = make(map[int32]time.Time)
+		config  = k.client.Config()
+		leaders = k.groupByLeader(topic, partitions, errs)
+	)
+
+	for _, l := range leaders {
+		wg.Add(1)
+		go func(l *leaderPartitions) {
+			defer wg.Done()
+
+			req := &sarama.FetchRequest{
+				MinBytes:    1,
+				MaxWaitTime: int32(config.Consumer.MaxWaitTime / time.Millisecond),
+				Version:     2,
+			}
+			for _, p := range l.partitions {
+				req.AddBlock(topic, p, offsets[p], config.Consumer.Fetch.Default)
+			}
+
+			var resp *sarama.FetchResponse
+			err := withContext(ctx, func() (err error) {
+				resp, err = l.broker.Fetch(req)
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for _, p := range l.partitions {
+				if err != nil {
+					errs.Errors[p] = fmt.Errorf("unable to fetch messages from broker %d. err=%v", l.broker.ID(), err)
+					continue
+				}
+
+				ts, err := messageTimestamp(resp.GetBlock(topic, p), offsets[p])
+				if err != nil {
+					errs.Errors[p] = err
+					continue
+				}
+				res[p] = ts
+			}
+		}(l)
+	}
+
+	wg.Wait()
+
+	return res
+}
+
+// messageTimestamp finds the message at offset in the fetched block and returns its timestamp.
+func messageTimestamp(block *sarama.FetchResponseBlock, offset int64) (time.Time, error) {
+	switch {
+	case block == nil:
+		return time.Time{}, sarama.ErrIncompleteResponse
+	case block.Err != sarama.ErrNoError:
+		return time.Time{}, block.Err
+	case len(block.MsgSet.Messages) == 0 && block.MsgSet.PartialTrailingMessage:
+		return time.Time{}, sarama.ErrMessageTooLarge
+	}
+
+	for _, msgBlock := range block.MsgSet.Messages {
+		messages := msgBlock.Messages()
+		for _, msg := range messages {
+			// Since 0.10 the offsets of compressed messages are relative to the wrapper message.
+			// This is the same computation as in the sarama consumer.
+			o := msg.Offset
+			if msg.Msg.Version >= 1 {
+				o += msgBlock.Offset - messages[len(messages)-1].Offset
+			}
+			if o < offset {
+				continue
+			}
+
+			ts := msg.Msg.Timestamp
+			if ts.IsZero() {
+				// Fall back to the timestamp of the wrapper message if the inner one has none.
+				ts = msgBlock.Msg.Timestamp
+			}
+			if ts.IsZero() {
+				return time.Time{}, fmt.Errorf("message at offset %d has no timestamp", o)
+			}
+
+			return ts, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("message at offset %d not found", offset)
+}
@@ -0,0 +1,244 @@
+package koff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestLag(t *testing.T) {
+	testCases := []struct {
+		newest    int64
+		committed int64
+		lag       int64
+		ok        bool
+	}{
+		{999, 1000, 0, true},
+		{999, 999, 1, true},
+		{999, 900, 100, true},
+		// The log was truncated below a stale committed offset.
+		{999, 1200, 0, true},
+		// The consumer group never committed on the partition.
+		{999, -1, 0, false},
+		// Empty partition.
+		{-1, 0, 0, true},
+	}
+
+	for _, tc := range testCases {
+		lag, ok := koff.Lag(tc.newest, tc.committed)
+		require.Equal(t, tc.lag, lag, "newest=%d committed=%d", tc.newest, tc.committed)
+		require.Equal(t, tc.ok, ok, "newest=%d committed=%d", tc.newest, tc.committed)
+	}
+}
+
+func TestGetLagReport(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	report, err := k.GetLagReport("myConsumerGroup", "foobar", koff.KafkaOffsetVersion)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(report))
+
+	require.Equal(t, int64(999), report[0].Newest)
+	require.Equal(t, int64(800), report[0].Committed)
+	require.Equal(t, int64(200), report[0].Drift)
+	require.NotNil(t, report[0].Owner)
+	require.Equal(t, "consumer-1-a", report[0].Owner.MemberID)
+	require.Equal(t, "consumer-1", report[0].Owner.ClientID)
+	require.Equal(t, "/10.0.0.1", report[0].Owner.ClientHost)
+
+	require.Equal(t, int64(2000), report[1].Drift)
+	require.NotNil(t, report[1].Owner)
+	require.Equal(t, "consumer-2-b", report[1].Owner.MemberID)
+}
+
+func TestGetLagReportUnassigned(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker.Addr(), 1)
+	metadataResponse.SetLeader("foobar", 0, 1)
+
+	offsetResponse := sarama.NewMockOffsetResponse(t)
+	offsetResponse.SetOffset("foobar", 0, sarama.OffsetNewest, 1000)
+
+	offsetFetchResponse := sarama.NewMockOffsetFetchResponse(t)
+	offsetFetchResponse.SetOffset("myConsumerGroup", "foobar", 0, 800, "", sarama.ErrNoError)
+
+	consumerMetadataResponse := sarama.NewMockConsumerMetadataResponse(t)
+	consumerMetadataResponse.SetCoordinator("myConsumerGroup", broker)
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":         metadataResponse,
+		"OffsetRequest":           offsetResponse,
+		"OffsetFetchRequest":      offsetFetchResponse,
+		"ConsumerMetadataRequest": consumerMetadataResponse,
+		"DescribeGroupsRequest": sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Groups: []*sarama.GroupDescription{
+				{GroupId: "myConsumerGroup", State: "Empty", ProtocolType: "consumer"},
+			},
+		}),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_9_0_0
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+
+	report, err := k.GetLagReport("myConsumerGroup", "foobar", koff.KafkaOffsetVersion)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(report))
+	require.Equal(t, int64(200), report[0].Drift)
+	require.Nil(t, report[0].Owner)
+}
+
+func TestGetLagReportOwnerUnknown(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker.Addr(), 1)
+	metadataResponse.SetLeader("foobar", 0, 1)
+
+	offsetResponse := sarama.NewMockOffsetResponse(t)
+	offsetResponse.SetOffset("foobar", 0, sarama.OffsetNewest, 1000)
+
+	offsetFetchResponse := sarama.NewMockOffsetFetchResponse(t)
+	offsetFetchResponse.SetOffset("myZKGroup", "foobar", 0, 800, "", sarama.ErrNoError)
+
+	consumerMetadataResponse := sarama.NewMockConsumerMetadataResponse(t)
+	consumerMetadataResponse.SetCoordinator("myZKGroup", broker)
+
+	// A group committing its offsets to ZooKeeper is unknown to the coordinator.
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":         metadataResponse,
+		"OffsetRequest":           offsetResponse,
+		"OffsetFetchRequest":      offsetFetchResponse,
+		"ConsumerMetadataRequest": consumerMetadataResponse,
+		"DescribeGroupsRequest": sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Groups: []*sarama.GroupDescription{
+				{GroupId: "myZKGroup", Err: sarama.ErrNotCoordinatorForConsumer},
+			},
+		}),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_9_0_0
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+
+	report, err := k.GetLagReport("myZKGroup", "foobar", koff.ZKOffsetVersion)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(report))
+	require.Equal(t, int64(200), report[0].Drift)
+	require.Nil(t, report[0].Owner)
+	require.True(t, report[0].OwnerUnknown)
+}
+
+func newTimestampedFetchResponse(topic string, messages map[int32]int64, ts map[int32]time.Time) *sarama.FetchResponse {
+	resp := &sarama.FetchResponse{Version: 2}
+	for p, offset := range messages {
+		resp.AddMessage(topic, p, nil, encoded("vincent"), offset)
+
+		msg := resp.Blocks[topic][p].MsgSet.Messages[0].Msg
+		msg.Version = 1
+		msg.Timestamp = ts[p]
+	}
+	return resp
+}
+
+func TestGetTimeLag(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker.Addr(), 1)
+	metadataResponse.SetLeader("foobar", 0, 1)
+	metadataResponse.SetLeader("foobar", 1, 1)
+
+	offsetResponse := sarama.NewMockOffsetResponse(t)
+	offsetResponse.SetOffset("foobar", 0, sarama.OffsetNewest, 1000)
+	offsetResponse.SetOffset("foobar", 1, sarama.OffsetNewest, 10000)
+
+	offsetFetchResponse := sarama.NewMockOffsetFetchResponse(t)
+	offsetFetchResponse.SetOffset("myConsumerGroup", "foobar", 0, 800, "", sarama.ErrNoError)
+	offsetFetchResponse.SetOffset("myConsumerGroup", "foobar", 1, 10000, "", sarama.ErrNoError)
+
+	consumerMetadataResponse := sarama.NewMockConsumerMetadataResponse(t)
+	consumerMetadataResponse.SetCoordinator("myConsumerGroup", broker)
+
+	now := time.Unix(1500000000, 0)
+
+	committedFetchResponse := newTimestampedFetchResponse("foobar",
+		map[int32]int64{0: 800},
+		map[int32]time.Time{0: now.Add(-90 * time.Second)},
+	)
+	newestFetchResponse := newTimestampedFetchResponse("foobar",
+		map[int32]int64{0: 999, 1: 9999},
+		map[int32]time.Time{0: now, 1: now},
+	)
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":         metadataResponse,
+		"OffsetRequest":           offsetResponse,
+		"OffsetFetchRequest":      offsetFetchResponse,
+		"ConsumerMetadataRequest": consumerMetadataResponse,
+		"FetchRequest":            sarama.NewMockSequence(committedFetchResponse, newestFetchResponse),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_0_0
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+
+	lags, err := k.GetTimeLag("myConsumerGroup", "foobar", koff.KafkaOffsetVersion)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(lags))
+
+	require.Equal(t, int64(800), lags[0].Committed)
+	require.Equal(t, int64(999), lags[0].Newest)
+	require.True(t, now.Equal(lags[0].NewestTimestamp))
+	require.True(t, now.Add(-90*time.Second).Equal(lags[0].CommittedTimestamp))
+	require.Equal(t, 90*time.Second, lags[0].Lag)
+
+	require.True(t, lags[1].CommittedTimestamp.IsZero())
+	require.Equal(t, time.Duration(0), lags[1].Lag)
+}
+
+func TestGetTimeLagOldVersion(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	_, err = k.GetTimeLag("myConsumerGroup", "foobar", koff.KafkaOffsetVersion)
+	require.NotNil(t, err)
+}
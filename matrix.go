@@ -0,0 +1,278 @@
+package koff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultLagMatrixWorkers is the number of consumer groups and topics GetLagMatrix queries concurrently when no number is given.
+const DefaultLagMatrixWorkers = 8
+
+// GroupErrors is returned when some consumer groups could not be queried.
+//
+// It maps consumer groups to the error which occurred. The data of the other groups is still returned alongside it.
+type GroupErrors map[string]error
+
+func (e GroupErrors) Error() string {
+	var groups []string
+	for group := range e {
+		groups = append(groups, group)
+	}
+
+	sort.Strings(groups)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "unable to query %d consumer group(s).", len(groups))
+	for _, group := range groups {
+		fmt.Fprintf(&buf, " %s: err=%v", group, e[group])
+	}
+
+	return buf.String()
+}
+
+// LagMatrixErrors is returned by GetLagMatrix when some brokers could not list their consumer groups or some consumer groups could not be queried.
+//
+// The lag of the other consumer groups is still returned alongside it.
+type LagMatrixErrors struct {
+	// Brokers maps the brokers which failed to list their consumer groups to the error which occurred.
+	Brokers BrokerErrors
+	// Groups maps the consumer groups which could not be queried to the error which occurred.
+	Groups GroupErrors
+}
+
+func (e LagMatrixErrors) Error() string {
+	switch {
+	case len(e.Brokers) > 0 && len(e.Groups) > 0:
+		return e.Brokers.Error() + " " + e.Groups.Error()
+	case len(e.Brokers) > 0:
+		return e.Brokers.Error()
+	default:
+		return e.Groups.Error()
+	}
+}
+
+// GroupTopicLag is the lag of a consumer group on one of its topics.
+type GroupTopicLag struct {
+	ConsumerGroup string
+	Topic         string
+
+	// TotalLag is the sum of the lag of the partitions.
+	TotalLag int64
+	// MaxLag is the lag of the partition which is the most behind.
+	MaxLag int64
+	// LaggingPartitions is the number of partitions with a lag.
+	LaggingPartitions int
+	// Partitions is the number of partitions the consumer group committed an offset on.
+	Partitions int
+}
+
+// LagMatrix is the lag of consumer groups on their topics, sorted by consumer group then topic.
+type LagMatrix []GroupTopicLag
+
+func (m LagMatrix) Len() int { return len(m) }
+func (m LagMatrix) Less(i, j int) bool {
+	if m[i].ConsumerGroup != m[j].ConsumerGroup {
+		return m[i].ConsumerGroup < m[j].ConsumerGroup
+	}
+	return m[i].Topic < m[j].Topic
+}
+func (m LagMatrix) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+
+// GetLagMatrix computes the lag of every consumer group of the cluster on every topic it consumes.
+//
+// The consumer groups are listed with ListConsumerGroups. The topics of a group are the ones its members subscribed to,
+// or every topic with a committed offset if the group has no member. At most workers groups, then topics, are queried at the same time.
+//
+// Returns the lag of each consumer group and topic. If some brokers failed to list their groups or some groups or topics failed,
+// the lag of the others is returned along with a LagMatrixErrors.
+func (k *Koff) GetLagMatrix(version OffsetVersion, workers int) (LagMatrix, error) {
+	return k.GetLagMatrixContext(context.Background(), version, workers)
+}
+
+// GetLagMatrixContext is like GetLagMatrix but honors the deadline and cancellation of ctx.
+func (k *Koff) GetLagMatrixContext(ctx context.Context, version OffsetVersion, workers int) (LagMatrix, error) {
+	groups, err := k.ListConsumerGroupsContext(ctx)
+	brokerErrors, ok := err.(BrokerErrors)
+	if err != nil && (!ok || len(groups) == 0) {
+		return nil, fmt.Errorf("unable to list consumer groups. err=%v", err)
+	}
+
+	var names []string
+	for group := range groups {
+		names = append(names, group)
+	}
+
+	sort.Strings(names)
+
+	res, err := k.GetGroupsLagMatrixContext(ctx, version, workers, names...)
+	groupErrors, ok := err.(GroupErrors)
+	if err != nil && !ok {
+		return nil, err
+	}
+
+	if len(brokerErrors) > 0 || len(groupErrors) > 0 {
+		return res, LagMatrixErrors{Brokers: brokerErrors, Groups: groupErrors}
+	}
+
+	return res, nil
+}
+
+// GetGroupsLagMatrix is like GetLagMatrix but only for the given consumer groups.
+//
+// If some groups or topics failed, the lag of the others is returned along with a GroupErrors.
+func (k *Koff) GetGroupsLagMatrix(version OffsetVersion, workers int, groups ...string) (LagMatrix, error) {
+	return k.GetGroupsLagMatrixContext(context.Background(), version, workers, groups...)
+}
+
+// GetGroupsLagMatrixContext is like GetGroupsLagMatrix but honors the deadline and cancellation of ctx.
+func (k *Koff) GetGroupsLagMatrixContext(ctx context.Context, version OffsetVersion, workers int, groups ...string) (LagMatrix, error) {
+	if workers <= 0 {
+		workers = DefaultLagMatrixWorkers
+	}
+
+	var (
+		mu        sync.Mutex
+		errs      = make(GroupErrors)
+		committed = make(map[string]map[string]map[int32]int64)
+		newest    = make(map[string]map[int32]int64)
+	)
+
+	// First the committed offsets of every group, then the newest offsets of every topic consumed by at least one group.
+	runWorkers(workers, groups, func(group string) {
+		offsets, err := k.getGroupCommittedOffsets(ctx, group, version)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			errs[group] = err
+		}
+		if len(offsets) > 0 {
+			committed[group] = offsets
+		}
+	})
+
+	var topics []string
+	seen := make(map[string]bool)
+	for _, offsets := range committed {
+		for topic := range offsets {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+
+	topicErrors := make(map[string]error)
+	runWorkers(workers, topics, func(topic string) {
+		offsets, err := k.GetNewestOffsetsContext(ctx, topic)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			topicErrors[topic] = fmt.Errorf("unable to get newest offsets of %q. err=%v", topic, err)
+		}
+		newest[topic] = offsets
+	})
+
+	var res LagMatrix
+	for group, offsets := range committed {
+		for topic, partitions := range offsets {
+			if err, ok := topicErrors[topic]; ok {
+				if _, ok := errs[group]; !ok {
+					errs[group] = err
+				}
+			}
+
+			lag := GroupTopicLag{ConsumerGroup: group, Topic: topic}
+			for p, offset := range partitions {
+				n, ok := newest[topic][p]
+				if !ok {
+					continue
+				}
+
+				lag.Partitions++
+
+				// The newest offset is the one of the last message, the lag is counted from the end of the log.
+				drift := n + 1 - offset
+				if drift <= 0 {
+					continue
+				}
+
+				lag.TotalLag += drift
+				lag.LaggingPartitions++
+				if drift > lag.MaxLag {
+					lag.MaxLag = drift
+				}
+			}
+
+			if lag.Partitions > 0 {
+				res = append(res, lag)
+			}
+		}
+	}
+
+	sort.Sort(res)
+
+	if len(errs) > 0 {
+		return res, errs
+	}
+
+	return res, nil
+}
+
+// getGroupCommittedOffsets retrieves the committed offsets of the consumer group on the topics its members subscribed to,
+// or on every topic if it has no member.
+//
+// Returns a map of topics to partitions to offset. Partial results are returned along with the error.
+func (k *Koff) getGroupCommittedOffsets(ctx context.Context, group string, version OffsetVersion) (map[string]map[int32]int64, error) {
+	desc, err := k.DescribeConsumerGroupContext(ctx, group)
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe consumer group. err=%v", err)
+	}
+
+	var topics []string
+	seen := make(map[string]bool)
+	for _, m := range desc.Members {
+		for _, topic := range m.Subscriptions {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+
+	if len(topics) == 0 {
+		topics = k.Topics()
+	}
+
+	return k.GetConsumerGroupOffsetsByTopicContext(ctx, group, version, topics...)
+}
+
+// runWorkers calls fn for every item with at most workers calls at the same time, and waits for all of them to return.
+func runWorkers(workers int, items []string, fn func(item string)) {
+	ch := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(items); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for item := range ch {
+				fn(item)
+			}
+		}()
+	}
+
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+
+	wg.Wait()
+}
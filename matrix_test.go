@@ -0,0 +1,89 @@
+package koff_test
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestGetGroupsLagMatrix(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	matrix, err := k.GetGroupsLagMatrix(koff.KafkaOffsetVersion, 2, "myConsumerGroup", "myNewGroup", "myBrokenGroup")
+	require.NotNil(t, err)
+
+	gerr, ok := err.(koff.GroupErrors)
+	require.True(t, ok)
+	require.Equal(t, 1, len(gerr))
+	require.NotNil(t, gerr["myBrokenGroup"])
+
+	require.Equal(t, koff.LagMatrix{
+		{ConsumerGroup: "myBrokenGroup", Topic: "foobar", TotalLag: 100, MaxLag: 100, LaggingPartitions: 1, Partitions: 1},
+		{ConsumerGroup: "myConsumerGroup", Topic: "foobar", TotalLag: 2200, MaxLag: 2000, LaggingPartitions: 2, Partitions: 2},
+	}, matrix)
+}
+
+func TestGetLagMatrixBrokerErrors(t *testing.T) {
+	broker1 := sarama.NewMockBroker(t, 1)
+	defer broker1.Close()
+
+	metadataResponse := sarama.NewMockMetadataResponse(t)
+	metadataResponse.SetBroker(broker1.Addr(), 1)
+	metadataResponse.SetBroker("localhost:1", 2)
+	metadataResponse.SetLeader("foobar", 0, 1)
+
+	offsetResponse := sarama.NewMockOffsetResponse(t)
+	offsetResponse.SetOffset("foobar", 0, sarama.OffsetNewest, 1000)
+
+	offsetFetchResponse := sarama.NewMockOffsetFetchResponse(t)
+	offsetFetchResponse.SetOffset("myConsumerGroup", "foobar", 0, 800, "", sarama.ErrNoError)
+
+	consumerMetadataResponse := sarama.NewMockConsumerMetadataResponse(t)
+	consumerMetadataResponse.SetCoordinator("myConsumerGroup", broker1)
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":         metadataResponse,
+		"OffsetRequest":           offsetResponse,
+		"OffsetFetchRequest":      offsetFetchResponse,
+		"ConsumerMetadataRequest": consumerMetadataResponse,
+		"ListGroupsRequest": sarama.NewMockWrapper(&sarama.ListGroupsResponse{
+			Groups: map[string]string{"myConsumerGroup": "consumer"},
+		}),
+		"DescribeGroupsRequest": sarama.NewMockWrapper(&sarama.DescribeGroupsResponse{
+			Groups: []*sarama.GroupDescription{
+				{GroupId: "myConsumerGroup", State: "Empty", ProtocolType: "consumer"},
+			},
+		}),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_9_0_0
+
+	client, err := sarama.NewClient([]string{broker1.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+	err = k.Init()
+	require.Nil(t, err)
+
+	matrix, err := k.GetLagMatrix(koff.KafkaOffsetVersion, 2)
+	require.NotNil(t, err)
+
+	merr, ok := err.(koff.LagMatrixErrors)
+	require.True(t, ok)
+	require.Equal(t, 1, len(merr.Brokers))
+	require.NotNil(t, merr.Brokers[2])
+	require.Equal(t, 0, len(merr.Groups))
+
+	require.Equal(t, koff.LagMatrix{
+		{ConsumerGroup: "myConsumerGroup", Topic: "foobar", TotalLag: 200, MaxLag: 200, LaggingPartitions: 1, Partitions: 1},
+	}, matrix)
+}
@@ -0,0 +1,199 @@
+package koff
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type resetKind int
+
+const (
+	resetToEarliest resetKind = iota
+	resetToLatest
+	resetToOffset
+	resetShiftBy
+	resetToTime
+)
+
+// ResetStrategy defines how the new offsets of a consumer group are computed when resetting them.
+type ResetStrategy struct {
+	kind   resetKind
+	offset int64
+	t      time.Time
+}
+
+// ResetToEarliest moves the consumer group to the oldest available offset.
+func ResetToEarliest() ResetStrategy {
+	return ResetStrategy{kind: resetToEarliest}
+}
+
+// ResetToLatest moves the consumer group to the end of the log, skipping every available message.
+func ResetToLatest() ResetStrategy {
+	return ResetStrategy{kind: resetToLatest}
+}
+
+// ResetToOffset moves the consumer group to the given offset.
+func ResetToOffset(offset int64) ResetStrategy {
+	return ResetStrategy{kind: resetToOffset, offset: offset}
+}
+
+// ResetShiftBy moves the committed offset of the consumer group by n, which can be negative to rewind.
+func ResetShiftBy(n int64) ResetStrategy {
+	return ResetStrategy{kind: resetShiftBy, offset: n}
+}
+
+// ResetToTime moves the consumer group to the first message at or after t.
+//
+// Partitions without such a message are moved to the end of the log.
+func ResetToTime(t time.Time) ResetStrategy {
+	return ResetStrategy{kind: resetToTime, t: t}
+}
+
+func (s ResetStrategy) String() string {
+	switch s.kind {
+	case resetToEarliest:
+		return "earliest"
+	case resetToLatest:
+		return "latest"
+	case resetToOffset:
+		return fmt.Sprintf("to-offset %d", s.offset)
+	case resetShiftBy:
+		return fmt.Sprintf("shift-by %d", s.offset)
+	case resetToTime:
+		return "to-datetime " + s.t.Format(time.RFC3339)
+	default:
+		return "unknown"
+	}
+}
+
+// OffsetReset is the committed offset of a consumer group on a partition before and after a reset.
+type OffsetReset struct {
+	Before int64
+	After  int64
+
+	// Clamped is true if the offset computed by the strategy was out of the available range and After was moved to its nearest bound.
+	Clamped bool
+}
+
+// PlanOffsetReset computes the new offsets of a consumer group according to the strategy, without committing anything.
+//
+// The new offsets are always kept in the available range of the partitions, between the oldest offset and the end of the log.
+// The offsets which had to be moved into it, like an explicit offset already removed by the retention, are marked as Clamped.
+//
+// Returns a map of partitions to offset reset.
+func (k *Koff) PlanOffsetReset(consumerGroup, topic string, version OffsetVersion, strategy ResetStrategy, partitions ...int32) (map[int32]OffsetReset, error) {
+	return k.PlanOffsetResetContext(context.Background(), consumerGroup, topic, version, strategy, partitions...)
+}
+
+// PlanOffsetResetContext is like PlanOffsetReset but honors the deadline and cancellation of ctx.
+func (k *Koff) PlanOffsetResetContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, strategy ResetStrategy, partitions ...int32) (map[int32]OffsetReset, error) {
+	oldestOffsets, err := k.GetOldestOffsetsContext(ctx, topic, partitions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get oldest offsets. err=%v", err)
+	}
+
+	newestOffsets, err := k.GetNewestOffsetsContext(ctx, topic, partitions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get newest offsets. err=%v", err)
+	}
+
+	cgroupOffsets, err := k.GetConsumerGroupOffsetsContext(ctx, consumerGroup, topic, version, partitions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get consumer group offsets. err=%v", err)
+	}
+
+	var timeOffsets map[int32]int64
+	if strategy.kind == resetToTime {
+		timeOffsets, err = k.GetOffsetsForTimeContext(ctx, topic, strategy.t, partitions...)
+		if perr, ok := err.(PartitionErrors); ok {
+			for p, err := range perr.Errors {
+				if err != ErrNoOffsetForTime {
+					return nil, fmt.Errorf("unable to get offset of partition %d for time. err=%v", p, err)
+				}
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to get offsets for time. err=%v", err)
+		}
+	}
+
+	res := make(map[int32]OffsetReset)
+	for p, before := range cgroupOffsets {
+		oldest := oldestOffsets[p]
+		logEnd := newestOffsets[p] + 1
+
+		var after int64
+		switch strategy.kind {
+		case resetToEarliest:
+			after = oldest
+		case resetToLatest:
+			after = logEnd
+		case resetToOffset:
+			after = strategy.offset
+		case resetShiftBy:
+			if before < 0 {
+				return nil, fmt.Errorf("unable to shift offset of partition %d. err=%v", p, ErrNoCommittedOffset)
+			}
+			after = before + strategy.offset
+		case resetToTime:
+			o, ok := timeOffsets[p]
+			if !ok {
+				o = logEnd
+			}
+			after = o
+		}
+
+		var clamped bool
+		if after < oldest {
+			after = oldest
+			clamped = true
+		}
+		if after > logEnd {
+			after = logEnd
+			clamped = true
+		}
+
+		res[p] = OffsetReset{
+			Before:  before,
+			After:   after,
+			Clamped: clamped,
+		}
+	}
+
+	return res, nil
+}
+
+// ResetConsumerGroupOffsets computes the new offsets of a consumer group like PlanOffsetReset and commits them.
+//
+// It refuses to commit if the consumer group still has active members and returns ErrGroupHasActiveMembers, or a DescribeGroupError
+// if it can't be described to check them, unless force is true.
+//
+// Returns a map of partitions to offset reset.
+func (k *Koff) ResetConsumerGroupOffsets(consumerGroup, topic string, version OffsetVersion, strategy ResetStrategy, force bool, partitions ...int32) (map[int32]OffsetReset, error) {
+	return k.ResetConsumerGroupOffsetsContext(context.Background(), consumerGroup, topic, version, strategy, force, partitions...)
+}
+
+// ResetConsumerGroupOffsetsContext is like ResetConsumerGroupOffsets but honors the deadline and cancellation of ctx.
+func (k *Koff) ResetConsumerGroupOffsetsContext(ctx context.Context, consumerGroup, topic string, version OffsetVersion, strategy ResetStrategy, force bool, partitions ...int32) (map[int32]OffsetReset, error) {
+	if !force {
+		if err := k.checkNoActiveMembers(ctx, consumerGroup); err != nil {
+			return nil, err
+		}
+	}
+
+	plan, err := k.PlanOffsetResetContext(ctx, consumerGroup, topic, version, strategy, partitions...)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int32]int64)
+	for p, r := range plan {
+		offsets[p] = r.After
+	}
+
+	if err := k.CommitConsumerGroupOffsetsContext(ctx, consumerGroup, topic, version, offsets); err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,60 @@
+package koff_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestPlanOffsetReset(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	testCases := []struct {
+		strategy koff.ResetStrategy
+		after    map[int32]int64
+		clamped  map[int32]bool
+	}{
+		{koff.ResetToEarliest(), map[int32]int64{0: 500, 1: 5000}, map[int32]bool{}},
+		{koff.ResetToLatest(), map[int32]int64{0: 1000, 1: 10000}, map[int32]bool{}},
+		{koff.ResetToOffset(900), map[int32]int64{0: 900, 1: 5000}, map[int32]bool{1: true}},
+		{koff.ResetToOffset(20000), map[int32]int64{0: 1000, 1: 10000}, map[int32]bool{0: true, 1: true}},
+		{koff.ResetShiftBy(-100), map[int32]int64{0: 700, 1: 7900}, map[int32]bool{}},
+		{koff.ResetShiftBy(500), map[int32]int64{0: 1000, 1: 8500}, map[int32]bool{0: true}},
+	}
+
+	for _, tc := range testCases {
+		plan, err := k.PlanOffsetReset("myConsumerGroup", "foobar", koff.KafkaOffsetVersion, tc.strategy)
+		require.Nil(t, err)
+		require.Equal(t, 2, len(plan))
+
+		require.Equal(t, int64(800), plan[0].Before, "strategy %s", tc.strategy)
+		require.Equal(t, int64(8000), plan[1].Before, "strategy %s", tc.strategy)
+		require.Equal(t, tc.after[0], plan[0].After, "strategy %s", tc.strategy)
+		require.Equal(t, tc.after[1], plan[1].After, "strategy %s", tc.strategy)
+		require.Equal(t, tc.clamped[0], plan[0].Clamped, "strategy %s", tc.strategy)
+		require.Equal(t, tc.clamped[1], plan[1].Clamped, "strategy %s", tc.strategy)
+	}
+}
+
+func TestResetConsumerGroupOffsets(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	_, err = k.ResetConsumerGroupOffsets("myConsumerGroup", "foobar", koff.KafkaOffsetVersion, koff.ResetToEarliest(), false)
+	require.Equal(t, koff.ErrGroupHasActiveMembers, err)
+
+	plan, err := k.ResetConsumerGroupOffsets("myConsumerGroup", "foobar", koff.KafkaOffsetVersion, koff.ResetToEarliest(), true)
+	require.Nil(t, err)
+	require.Equal(t, int64(500), plan[0].After)
+	require.Equal(t, int64(5000), plan[1].After)
+}
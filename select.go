@@ -0,0 +1,285 @@
+package koff
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// namePattern is a single pattern of a Selector.
+type namePattern struct {
+	name string
+	glob string
+	re   *regexp.Regexp
+}
+
+func (p namePattern) match(name string) bool {
+	switch {
+	case p.re != nil:
+		return p.re.MatchString(name)
+	case p.glob != "":
+		ok, _ := path.Match(p.glob, name)
+		return ok
+	default:
+		return p.name == name
+	}
+}
+
+// Selector selects names, like topics or consumer groups.
+//
+// It is a comma separated list of patterns, each one being either an exact name, a glob pattern as understood by path.Match
+// like orders.* or a regular expression enclosed in slashes like /^orders\.(eu|us)$/.
+// A name is selected if it matches any of the patterns.
+type Selector struct {
+	raw      string
+	patterns []namePattern
+}
+
+// ParseSelector parses the comma separated list of patterns s.
+func ParseSelector(s string) (*Selector, error) {
+	sel := &Selector{raw: s}
+
+	for len(s) > 0 {
+		var p string
+		if strings.HasPrefix(s, "/") {
+			// A regular expression can contain commas, it ends at the first slash followed by a comma.
+			end := strings.Index(s[1:], "/,")
+			if end < 0 {
+				if len(s) < 2 || !strings.HasSuffix(s, "/") {
+					return nil, fmt.Errorf("unterminated regular expression %q", s)
+				}
+				end = len(s) - 2
+			}
+			p, s = s[:end+2], s[end+2:]
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				end = len(s)
+			}
+			p, s = s[:end], s[end:]
+		}
+		s = strings.TrimPrefix(s, ",")
+
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+			continue
+		case len(p) >= 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/"):
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q. err=%v", p, err)
+			}
+			sel.patterns = append(sel.patterns, namePattern{re: re})
+		case strings.ContainsAny(p, "*?["):
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q. err=%v", p, err)
+			}
+			sel.patterns = append(sel.patterns, namePattern{glob: p})
+		default:
+			sel.patterns = append(sel.patterns, namePattern{name: p})
+		}
+	}
+
+	if len(sel.patterns) == 0 {
+		return nil, fmt.Errorf("empty selector %q", sel.raw)
+	}
+
+	return sel, nil
+}
+
+// Match reports whether the name is selected.
+func (s *Selector) Match(name string) bool {
+	for _, p := range s.patterns {
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the sorted names which are selected.
+func (s *Selector) Filter(names []string) []string {
+	var res []string
+	for _, name := range names {
+		if s.Match(name) {
+			res = append(res, name)
+		}
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// IsLiteral reports whether the selector is only made of exact names, which can be used without listing the available names first.
+func (s *Selector) IsLiteral() bool {
+	for _, p := range s.patterns {
+		if p.re != nil || p.glob != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Names returns the exact names of the selector, without duplicates, in their original order.
+func (s *Selector) Names() []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, p := range s.patterns {
+		if p.name == "" || seen[p.name] {
+			continue
+		}
+		seen[p.name] = true
+		res = append(res, p.name)
+	}
+	return res
+}
+
+func (s *Selector) String() string {
+	return s.raw
+}
+
+// MaxPartitionRange is the maximum number of partitions in a range given to ParsePartitions, far more than a topic can have.
+const MaxPartitionRange = 100000
+
+// ParsePartitions parses a comma separated list of partitions and inclusive ranges of partitions, like 0-3,7.
+// Ranges of more than MaxPartitionRange partitions are rejected.
+//
+// Returns the sorted partitions without duplicates, or nil if s is empty which means all the partitions.
+func ParsePartitions(s string) ([]int32, error) {
+	seen := make(map[int32]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			from, to = part[:i], part[i+1:]
+		}
+
+		start, err := strconv.ParseInt(strings.TrimSpace(from), 10, 32)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid partition %q", part)
+		}
+		end, err := strconv.ParseInt(strings.TrimSpace(to), 10, 32)
+		if err != nil || end < 0 {
+			return nil, fmt.Errorf("invalid partition %q", part)
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid partition range %q", part)
+		}
+		if end-start >= MaxPartitionRange {
+			return nil, fmt.Errorf("partition range %q is too large, it can have at most %d partitions", part, MaxPartitionRange)
+		}
+
+		for p := start; p <= end; p++ {
+			seen[int32(p)] = true
+		}
+	}
+
+	if len(seen) == 0 {
+		return nil, nil
+	}
+
+	var keys []int
+	for p := range seen {
+		keys = append(keys, int(p))
+	}
+
+	sort.Ints(keys)
+
+	res := make([]int32, len(keys))
+	for i, p := range keys {
+		res[i] = int32(p)
+	}
+
+	return res, nil
+}
+
+// Partitions returns the partitions of the topic, loading its metadata if it's not cached.
+func (k *Koff) Partitions(topic string) ([]int32, error) {
+	return k.PartitionsContext(context.Background(), topic)
+}
+
+// PartitionsContext is like Partitions but honors the deadline and cancellation of ctx.
+func (k *Koff) PartitionsContext(ctx context.Context, topic string) ([]int32, error) {
+	loaded, err := k.getPartitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	return loaded[topic], nil
+}
+
+// SelectTopics returns the sorted topics known by the Koff instance which are selected by sel.
+func (k *Koff) SelectTopics(sel *Selector) []string {
+	return sel.Filter(k.Topics())
+}
+
+// SelectPartitions returns the partitions of the topic which are in the selection, or all of them if the selection is empty.
+//
+// Partitions of the selection the topic doesn't have are left out.
+func (k *Koff) SelectPartitions(topic string, selection []int32) ([]int32, error) {
+	return k.SelectPartitionsContext(context.Background(), topic, selection)
+}
+
+// SelectPartitionsContext is like SelectPartitions but honors the deadline and cancellation of ctx.
+func (k *Koff) SelectPartitionsContext(ctx context.Context, topic string, selection []int32) ([]int32, error) {
+	partitions, err := k.PartitionsContext(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(selection) == 0 {
+		return partitions, nil
+	}
+
+	selected := make(map[int32]bool)
+	for _, p := range selection {
+		selected[p] = true
+	}
+
+	var res []int32
+	for _, p := range partitions {
+		if selected[p] {
+			res = append(res, p)
+		}
+	}
+
+	return res, nil
+}
+
+// SelectConsumerGroups returns the sorted consumer groups which are selected by sel.
+//
+// If the selector is only made of exact names they are returned as is, otherwise the consumer groups are listed
+// with ListConsumerGroups. If some brokers failed, the groups of the others are selected and returned along with a BrokerErrors.
+func (k *Koff) SelectConsumerGroups(sel *Selector) ([]string, error) {
+	return k.SelectConsumerGroupsContext(context.Background(), sel)
+}
+
+// SelectConsumerGroupsContext is like SelectConsumerGroups but honors the deadline and cancellation of ctx.
+func (k *Koff) SelectConsumerGroupsContext(ctx context.Context, sel *Selector) ([]string, error) {
+	if sel.IsLiteral() {
+		res := sel.Names()
+		sort.Strings(res)
+		return res, nil
+	}
+
+	groups, err := k.ListConsumerGroupsContext(ctx)
+	if _, ok := err.(BrokerErrors); err != nil && !ok {
+		return nil, err
+	}
+
+	var names []string
+	for group := range groups {
+		names = append(names, group)
+	}
+
+	return sel.Filter(names), err
+}
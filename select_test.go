@@ -0,0 +1,86 @@
+package koff_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestParseSelector(t *testing.T) {
+	names := []string{"orders.eu", "orders.us", "orders", "payments", "audit.log"}
+
+	testCases := []struct {
+		selector string
+		literal  bool
+		exp      []string
+	}{
+		{"payments", true, []string{"payments"}},
+		{"payments,orders", true, []string{"orders", "payments"}},
+		{"orders.*", false, []string{"orders.eu", "orders.us"}},
+		{"orders*,audit.?og", false, []string{"audit.log", "orders", "orders.eu", "orders.us"}},
+		{`/^orders\.(eu|us)$/`, false, []string{"orders.eu", "orders.us"}},
+		{`/^[a-z]{1,6}$/,audit.log`, false, []string{"audit.log", "orders"}},
+		{"unknown", true, nil},
+	}
+
+	for _, tc := range testCases {
+		sel, err := koff.ParseSelector(tc.selector)
+		require.Nil(t, err)
+		require.Equal(t, tc.literal, sel.IsLiteral(), tc.selector)
+		require.Equal(t, tc.exp, sel.Filter(names), tc.selector)
+	}
+
+	for _, s := range []string{"", ",", "/orders", "orders[", "/(/"} {
+		_, err := koff.ParseSelector(s)
+		require.NotNil(t, err, s)
+	}
+}
+
+func TestParsePartitions(t *testing.T) {
+	partitions, err := koff.ParsePartitions("0-3,7,2")
+	require.Nil(t, err)
+	require.Equal(t, []int32{0, 1, 2, 3, 7}, partitions)
+
+	partitions, err = koff.ParsePartitions("")
+	require.Nil(t, err)
+	require.Nil(t, partitions)
+
+	partitions, err = koff.ParsePartitions(fmt.Sprintf("1-%d", koff.MaxPartitionRange))
+	require.Nil(t, err)
+	require.Equal(t, koff.MaxPartitionRange, len(partitions))
+
+	for _, s := range []string{"a", "3-1", "-1", "1-", "0-2000000000", fmt.Sprintf("0-%d", koff.MaxPartitionRange)} {
+		_, err := koff.ParsePartitions(s)
+		require.NotNil(t, err, s)
+	}
+}
+
+func TestSelectPartitions(t *testing.T) {
+	client, closeFn := getClient(t)
+	defer closeFn()
+
+	k := koff.New(client)
+	err := k.Init()
+	require.Nil(t, err)
+
+	sel, err := koff.ParseSelector("foo*")
+	require.Nil(t, err)
+	require.Equal(t, []string{"foobar"}, k.SelectTopics(sel))
+
+	partitions, err := k.SelectPartitions("foobar", nil)
+	require.Nil(t, err)
+	require.Equal(t, []int32{0, 1}, partitions)
+
+	partitions, err = k.SelectPartitions("foobar", []int32{1, 2, 3})
+	require.Nil(t, err)
+	require.Equal(t, []int32{1}, partitions)
+
+	sel, err = koff.ParseSelector("myNewGroup,myConsumerGroup")
+	require.Nil(t, err)
+
+	groups, err := k.SelectConsumerGroups(sel)
+	require.Nil(t, err)
+	require.Equal(t, []string{"myConsumerGroup", "myNewGroup"}, groups)
+}
@@ -0,0 +1,207 @@
+package koff
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LagStatus is the health of a consumer group on a partition, evaluated over a window of samples.
+//
+// The statuses are ordered by severity.
+type LagStatus int
+
+const (
+	// StatusOK means the consumer group is keeping up.
+	StatusOK LagStatus = iota
+	// StatusWarning means the lag grew at every sample of the window.
+	StatusWarning
+	// StatusStalled means the consumer group commits but its lag does not decrease.
+	StatusStalled
+	// StatusStopped means the consumer group did not commit during the window while it has lag.
+	StatusStopped
+	// StatusError means the committed offset is below the oldest offset retained by the partition.
+	StatusError
+)
+
+func (s LagStatus) String() string {
+	switch s {
+	case StatusOK:
+		return "OK"
+	case StatusWarning:
+		return "WARNING"
+	case StatusStalled:
+		return "STALLED"
+	case StatusStopped:
+		return "STOPPED"
+	case StatusError:
+		return "ERROR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// PartitionStatus is the status of a partition with the reason for it.
+type PartitionStatus struct {
+	Status LagStatus
+	Reason string
+
+	// Lag is the lag of the last sample.
+	Lag int64
+	// Samples is the number of samples the status was evaluated on.
+	Samples int
+}
+
+// GroupStatus is the status of a consumer group on a topic, which is the worst status of its partitions.
+type GroupStatus struct {
+	Status     LagStatus
+	Partitions map[int32]PartitionStatus
+}
+
+// LagEvaluator evaluates the status of partitions over a sliding window of samples.
+//
+// It is safe to use from multiple goroutines.
+type LagEvaluator struct {
+	mu      sync.Mutex
+	size    int
+	windows map[topicAndPartition][]LagSample
+}
+
+// NewLagEvaluator creates a new LagEvaluator keeping the last size samples of each partition.
+func NewLagEvaluator(size int) *LagEvaluator {
+	if size < 2 {
+		size = 2
+	}
+
+	return &LagEvaluator{
+		size:    size,
+		windows: make(map[topicAndPartition][]LagSample),
+	}
+}
+
+// Add records a sample of a partition, dropping the oldest sample if the window is full.
+func (e *LagEvaluator) Add(topic string, partition int32, sample LagSample) {
+	key := topicAndPartition{topic: topic, partition: partition}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	window := append(e.windows[key], sample)
+	if len(window) > e.size {
+		window = window[len(window)-e.size:]
+	}
+	e.windows[key] = window
+}
+
+// Track records the samples of the partitions of a topic, as returned by GetNewestOffsets and GetConsumerGroupOffsets.
+//
+// Partitions on which the consumer group never committed an offset are skipped.
+func (e *LagEvaluator) Track(topic string, at time.Time, newest, committed map[int32]int64) {
+	for p, c := range committed {
+		n, ok := newest[p]
+		if !ok || c < 0 {
+			continue
+		}
+
+		e.Add(topic, p, LagSample{Time: at, Newest: n, Committed: c})
+	}
+}
+
+// Evaluate evaluates the status of every partition of the topic which has samples.
+//
+// oldest are the oldest offsets of the partitions as returned by GetOldestOffsets, it is used to detect
+// committed offsets which are not retained anymore. It can be nil.
+func (e *LagEvaluator) Evaluate(topic string, oldest map[int32]int64) GroupStatus {
+	res := GroupStatus{
+		Partitions: make(map[int32]PartitionStatus),
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, window := range e.windows {
+		if key.topic != topic {
+			continue
+		}
+
+		o, ok := oldest[key.partition]
+		if !ok {
+			o = -1
+		}
+
+		status := evaluateWindow(window, o)
+		if status.Status > res.Status {
+			res.Status = status.Status
+		}
+		res.Partitions[key.partition] = status
+	}
+
+	return res
+}
+
+// evaluateWindow evaluates the status of a single partition. oldest is -1 if unknown.
+func evaluateWindow(window []LagSample, oldest int64) PartitionStatus {
+	first, last := window[0], window[len(window)-1]
+
+	lag, ok := Lag(last.Newest, last.Committed)
+
+	res := PartitionStatus{
+		Lag:     lag,
+		Samples: len(window),
+	}
+
+	if !ok {
+		res.Reason = "no committed offset"
+		return res
+	}
+
+	if oldest >= 0 && last.Committed < oldest {
+		res.Status = StatusError
+		res.Reason = fmt.Sprintf("committed offset %d is below the oldest offset %d", last.Committed, oldest)
+		return res
+	}
+
+	if len(window) < 2 {
+		res.Reason = "not enough samples"
+		return res
+	}
+
+	var (
+		growing    = true
+		decreasing bool
+	)
+	for i := 1; i < len(window); i++ {
+		previousLag, _ := Lag(window[i-1].Newest, window[i-1].Committed)
+		lag, _ := Lag(window[i].Newest, window[i].Committed)
+
+		if lag <= 0 || previousLag <= 0 {
+			res.Reason = "lag was zero within the window"
+			return res
+		}
+
+		if lag <= previousLag {
+			growing = false
+		}
+		if lag < previousLag {
+			decreasing = true
+		}
+	}
+
+	firstLag, _ := Lag(first.Newest, first.Committed)
+
+	switch {
+	case last.Committed == first.Committed:
+		res.Status = StatusStopped
+		res.Reason = fmt.Sprintf("committed offset stuck at %d over %s while lag is %d", last.Committed, last.Time.Sub(first.Time), res.Lag)
+	case growing:
+		res.Status = StatusWarning
+		res.Reason = fmt.Sprintf("lag grew at every sample from %d to %d", firstLag, res.Lag)
+	case !decreasing:
+		res.Status = StatusStalled
+		res.Reason = fmt.Sprintf("committed offset moved from %d to %d but lag did not decrease from %d", first.Committed, last.Committed, firstLag)
+	default:
+		res.Reason = fmt.Sprintf("consumer group is committing, lag went from %d to %d", firstLag, res.Lag)
+	}
+
+	return res
+}
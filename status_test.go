@@ -0,0 +1,71 @@
+package koff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestLagEvaluator(t *testing.T) {
+	testCases := []struct {
+		newest    []int64
+		committed []int64
+		oldest    int64
+		status    koff.LagStatus
+	}{
+		{[]int64{1000, 1100, 1200}, []int64{900, 1100, 1150}, 0, koff.StatusOK},
+		{[]int64{1000, 1100, 1200}, []int64{900, 950, 1000}, 0, koff.StatusWarning},
+		{[]int64{1000, 1100, 1200}, []int64{900, 1000, 1050}, 0, koff.StatusStalled},
+		{[]int64{1000, 1000, 1000}, []int64{900, 900, 900}, 0, koff.StatusStopped},
+		{[]int64{1000, 1100, 1200}, []int64{800, 800, 800}, 900, koff.StatusError},
+		{[]int64{1000, 1100, 1200}, []int64{900, 1000, 1100}, 0, koff.StatusStalled},
+		{[]int64{999, 1099, 1199}, []int64{1000, 1000, 1000}, 0, koff.StatusOK},
+		// One message is left unprocessed.
+		{[]int64{1000, 1000, 1000}, []int64{1000, 1000, 1000}, 0, koff.StatusStopped},
+		{[]int64{1000}, []int64{900}, 0, koff.StatusOK},
+		// The consumer group never committed on the partition, it is not a retention loss.
+		{[]int64{1000, 1100, 1200}, []int64{-1, -1, -1}, 900, koff.StatusOK},
+	}
+
+	now := time.Now()
+	for _, tc := range testCases {
+		e := koff.NewLagEvaluator(3)
+		for i := range tc.newest {
+			e.Add("foobar", 0, koff.LagSample{Time: now.Add(time.Duration(i) * time.Second), Newest: tc.newest[i], Committed: tc.committed[i]})
+		}
+
+		status := e.Evaluate("foobar", map[int32]int64{0: tc.oldest})
+		require.Equal(t, tc.status, status.Status, "newest=%v committed=%v", tc.newest, tc.committed)
+		require.Equal(t, tc.status, status.Partitions[0].Status)
+		require.NotEqual(t, "", status.Partitions[0].Reason)
+		require.Equal(t, len(tc.newest), status.Partitions[0].Samples)
+	}
+}
+
+func TestLagEvaluatorWindow(t *testing.T) {
+	e := koff.NewLagEvaluator(2)
+	now := time.Now()
+
+	// The stuck samples slide out of the window once the consumer group commits again.
+	e.Track("foobar", now, map[int32]int64{0: 999, 1: 999}, map[int32]int64{0: 900, 1: 1000})
+	e.Track("foobar", now.Add(time.Second), map[int32]int64{0: 999, 1: 999}, map[int32]int64{0: 900, 1: 1000})
+
+	status := e.Evaluate("foobar", nil)
+	require.Equal(t, koff.StatusStopped, status.Status)
+	require.Equal(t, koff.StatusStopped, status.Partitions[0].Status)
+	require.Equal(t, koff.StatusOK, status.Partitions[1].Status)
+
+	e.Track("foobar", now.Add(2*time.Second), map[int32]int64{0: 999, 1: 999}, map[int32]int64{0: 950, 1: 1000})
+
+	status = e.Evaluate("foobar", nil)
+	require.Equal(t, koff.StatusOK, status.Status)
+	require.Equal(t, int64(50), status.Partitions[0].Lag)
+	require.Equal(t, 2, status.Partitions[0].Samples)
+
+	require.Equal(t, 0, len(e.Evaluate("other", nil).Partitions))
+
+	e.Track("uncommitted", now, map[int32]int64{0: 1000}, map[int32]int64{0: -1})
+	require.Equal(t, 0, len(e.Evaluate("uncommitted", map[int32]int64{0: 500}).Partitions))
+}
@@ -0,0 +1,144 @@
+package koff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// PartitionReplicas describes the replication of a partition.
+type PartitionReplicas struct {
+	// Leader is the ID of the broker leading the partition, -1 if there is none.
+	Leader int32
+	// Replicas is the list of brokers assigned to the partition, the first one being the preferred leader.
+	Replicas []int32
+	// ISR is the list of replicas in sync with the leader.
+	ISR []int32
+
+	// Offline is true if the partition has no leader.
+	Offline bool
+	// UnderReplicated is true if some replicas are not in sync with the leader.
+	UnderReplicated bool
+	// NonPreferredLeader is true if the partition is not led by its preferred leader.
+	NonPreferredLeader bool
+}
+
+func newPartitionReplicas(pm *sarama.PartitionMetadata) PartitionReplicas {
+	res := PartitionReplicas{
+		Leader:   pm.Leader,
+		Replicas: pm.Replicas,
+		ISR:      pm.Isr,
+	}
+
+	if pm.Err == sarama.ErrLeaderNotAvailable || pm.Leader < 0 {
+		res.Leader = -1
+		res.Offline = true
+	}
+	res.UnderReplicated = len(pm.Isr) < len(pm.Replicas)
+	res.NonPreferredLeader = !res.Offline && len(pm.Replicas) > 0 && pm.Leader != pm.Replicas[0]
+
+	return res
+}
+
+// DescribeTopic retrieves the leader, the replicas and the in sync replicas of each partition of the topic.
+//
+// The metadata is requested to the brokers instead of being taken from the client cache, which is sorted and loses the preferred leader.
+//
+// Returns a map of partitions to replicas.
+func (k *Koff) DescribeTopic(topic string) (map[int32]PartitionReplicas, error) {
+	return k.DescribeTopicContext(context.Background(), topic)
+}
+
+// DescribeTopicContext is like DescribeTopic but honors the deadline and cancellation of ctx.
+func (k *Koff) DescribeTopicContext(ctx context.Context, topic string) (map[int32]PartitionReplicas, error) {
+	resp, err := k.getMetadata(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tm := range resp.Topics {
+		if tm.Name != topic {
+			continue
+		}
+		if tm.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("unable to describe topic %q. err=%v", topic, tm.Err)
+		}
+
+		res := make(map[int32]PartitionReplicas)
+		for _, pm := range tm.Partitions {
+			res[pm.ID] = newPartitionReplicas(pm)
+		}
+
+		return res, nil
+	}
+
+	return nil, sarama.ErrUnknownTopicOrPartition
+}
+
+// GetUnderReplicatedPartitions retrieves the partitions of every topic of the cluster which are offline or under replicated.
+//
+// Returns a map of topics to partitions to replicas. Topics without such partitions are left out.
+func (k *Koff) GetUnderReplicatedPartitions() (map[string]map[int32]PartitionReplicas, error) {
+	return k.GetUnderReplicatedPartitionsContext(context.Background())
+}
+
+// GetUnderReplicatedPartitionsContext is like GetUnderReplicatedPartitions but honors the deadline and cancellation of ctx.
+func (k *Koff) GetUnderReplicatedPartitionsContext(ctx context.Context) (map[string]map[int32]PartitionReplicas, error) {
+	resp, err := k.getMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]map[int32]PartitionReplicas)
+	for _, tm := range resp.Topics {
+		for _, pm := range tm.Partitions {
+			r := newPartitionReplicas(pm)
+			if !r.Offline && !r.UnderReplicated {
+				continue
+			}
+
+			if _, ok := res[tm.Name]; !ok {
+				res[tm.Name] = make(map[int32]PartitionReplicas)
+			}
+			res[tm.Name][pm.ID] = r
+		}
+	}
+
+	return res, nil
+}
+
+// getMetadata requests the metadata of the topics, or of every topic if none is provided, to the first broker which answers.
+func (k *Koff) getMetadata(ctx context.Context, topics ...string) (*sarama.MetadataResponse, error) {
+	brokers := k.client.Brokers()
+	sort.Sort(brokersByID(brokers))
+
+	lastErr := sarama.ErrOutOfBrokers
+	for _, broker := range brokers {
+		if err := k.connectBroker(ctx, broker); err != nil {
+			lastErr = err
+			continue
+		}
+
+		var resp *sarama.MetadataResponse
+		err := withContext(ctx, func() (err error) {
+			resp, err = broker.GetMetadata(&sarama.MetadataRequest{Topics: topics})
+			return err
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("unable to get metadata. err=%v", lastErr)
+}
+
+type brokersByID []*sarama.Broker
+
+func (b brokersByID) Len() int           { return len(b) }
+func (b brokersByID) Less(i, j int) bool { return b[i].ID() < b[j].ID() }
+func (b brokersByID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
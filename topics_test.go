@@ -0,0 +1,51 @@
+package koff_test
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestDescribeTopic(t *testing.T) {
+	broker1 := sarama.NewMockBroker(t, 1)
+	defer broker1.Close()
+
+	metadataResponse := &sarama.MetadataResponse{}
+	metadataResponse.AddBroker(broker1.Addr(), 1)
+	metadataResponse.AddBroker("localhost:1", 2)
+	metadataResponse.AddBroker("localhost:2", 3)
+	metadataResponse.AddTopicPartition("foobar", 0, 1, []int32{1, 2, 3}, []int32{1, 2, 3}, sarama.ErrNoError)
+	metadataResponse.AddTopicPartition("foobar", 1, 1, []int32{2, 1, 3}, []int32{1, 2}, sarama.ErrNoError)
+	metadataResponse.AddTopicPartition("foobar", 2, -1, []int32{3, 2, 1}, nil, sarama.ErrLeaderNotAvailable)
+	metadataResponse.AddTopicPartition("healthy", 0, 1, []int32{1, 2}, []int32{1, 2}, sarama.ErrNoError)
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockWrapper(metadataResponse),
+	})
+
+	config := sarama.NewConfig()
+	// The client retries the metadata requests while a partition has no leader.
+	config.Metadata.Retry.Max = 0
+
+	client, err := sarama.NewClient([]string{broker1.Addr()}, config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	k := koff.New(client)
+
+	replicas, err := k.DescribeTopic("foobar")
+	require.Nil(t, err)
+	require.Equal(t, map[int32]koff.PartitionReplicas{
+		0: {Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		1: {Leader: 1, Replicas: []int32{2, 1, 3}, ISR: []int32{1, 2}, UnderReplicated: true, NonPreferredLeader: true},
+		2: {Leader: -1, Replicas: []int32{3, 2, 1}, ISR: nil, Offline: true, UnderReplicated: true},
+	}, replicas)
+
+	underReplicated, err := k.GetUnderReplicatedPartitions()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(underReplicated))
+	require.Equal(t, 2, len(underReplicated["foobar"]))
+	require.True(t, underReplicated["foobar"][2].Offline)
+}
@@ -0,0 +1,143 @@
+package koff
+
+import (
+	"sync"
+	"time"
+)
+
+// LagTrend is the direction in which the lag of a consumer group on a partition is going.
+type LagTrend int
+
+const (
+	// LagStable means the lag did not change.
+	LagStable LagTrend = iota
+	// LagGrowing means the consumer group is falling behind.
+	LagGrowing
+	// LagShrinking means the consumer group is catching up.
+	LagShrinking
+)
+
+func (t LagTrend) String() string {
+	switch t {
+	case LagStable:
+		return "stable"
+	case LagGrowing:
+		return "growing"
+	case LagShrinking:
+		return "shrinking"
+	default:
+		return "unknown"
+	}
+}
+
+// LagSample is a snapshot of the newest and committed offsets of a partition.
+type LagSample struct {
+	Time      time.Time
+	Newest    int64
+	Committed int64
+}
+
+// LagRates are the rates of a partition computed from two successive samples.
+type LagRates struct {
+	// Lag is the lag of the current sample, as computed by Lag.
+	Lag int64
+
+	// ProduceRate is the number of messages produced per second.
+	ProduceRate float64
+	// ConsumeRate is the number of messages consumed per second.
+	ConsumeRate float64
+
+	Trend LagTrend
+	// ETA is the estimated time for the consumer group to catch up at the current rates.
+	// It is -1 if the consumer group is not catching up.
+	ETA time.Duration
+}
+
+// LagTracker computes the produce and consume rates of partitions from successive samples.
+//
+// It is safe to use from multiple goroutines.
+type LagTracker struct {
+	mu       sync.Mutex
+	previous map[topicAndPartition]LagSample
+}
+
+// NewLagTracker creates a new LagTracker.
+func NewLagTracker() *LagTracker {
+	return &LagTracker{
+		previous: make(map[topicAndPartition]LagSample),
+	}
+}
+
+// Add records a sample of a partition and computes the rates since the previous sample.
+//
+// The boolean is false if there was no previous sample to compare to.
+func (t *LagTracker) Add(topic string, partition int32, sample LagSample) (LagRates, bool) {
+	key := topicAndPartition{topic: topic, partition: partition}
+
+	t.mu.Lock()
+	previous, ok := t.previous[key]
+	t.previous[key] = sample
+	t.mu.Unlock()
+
+	if !ok {
+		return LagRates{}, false
+	}
+
+	return computeRates(previous, sample), true
+}
+
+// Track records the samples of the partitions of a topic, as returned by GetNewestOffsets and GetConsumerGroupOffsets.
+//
+// Partitions on which the consumer group never committed an offset are skipped.
+//
+// Returns a map of partitions to rates, only for partitions which have a previous sample.
+func (t *LagTracker) Track(topic string, at time.Time, newest, committed map[int32]int64) map[int32]LagRates {
+	res := make(map[int32]LagRates)
+	for p, c := range committed {
+		n, ok := newest[p]
+		if !ok || c < 0 {
+			continue
+		}
+
+		rates, ok := t.Add(topic, p, LagSample{Time: at, Newest: n, Committed: c})
+		if ok {
+			res[p] = rates
+		}
+	}
+
+	return res
+}
+
+func computeRates(previous, current LagSample) LagRates {
+	lag, _ := Lag(current.Newest, current.Committed)
+	previousLag, _ := Lag(previous.Newest, previous.Committed)
+
+	res := LagRates{
+		Lag: lag,
+		ETA: -1,
+	}
+
+	switch {
+	case res.Lag > previousLag:
+		res.Trend = LagGrowing
+	case res.Lag < previousLag:
+		res.Trend = LagShrinking
+	}
+
+	elapsed := current.Time.Sub(previous.Time).Seconds()
+	if elapsed <= 0 {
+		return res
+	}
+
+	res.ProduceRate = float64(current.Newest-previous.Newest) / elapsed
+	res.ConsumeRate = float64(current.Committed-previous.Committed) / elapsed
+
+	switch catchUpRate := res.ConsumeRate - res.ProduceRate; {
+	case res.Lag <= 0:
+		res.ETA = 0
+	case catchUpRate > 0:
+		res.ETA = time.Duration(float64(res.Lag) / catchUpRate * float64(time.Second))
+	}
+
+	return res
+}
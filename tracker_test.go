@@ -0,0 +1,58 @@
+package koff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vrischmann/koff"
+)
+
+func TestLagTracker(t *testing.T) {
+	tracker := koff.NewLagTracker()
+	now := time.Now()
+
+	_, ok := tracker.Add("foobar", 0, koff.LagSample{Time: now, Newest: 999, Committed: 800})
+	require.False(t, ok)
+
+	// 100 messages produced and 200 consumed in 10s: the lag shrinks by 10 messages per second.
+	rates, ok := tracker.Add("foobar", 0, koff.LagSample{Time: now.Add(10 * time.Second), Newest: 1099, Committed: 1000})
+	require.True(t, ok)
+	require.Equal(t, int64(100), rates.Lag)
+	require.Equal(t, 10.0, rates.ProduceRate)
+	require.Equal(t, 20.0, rates.ConsumeRate)
+	require.Equal(t, koff.LagShrinking, rates.Trend)
+	require.Equal(t, 10*time.Second, rates.ETA)
+
+	// Nothing consumed: the lag grows and the consumer group never catches up.
+	rates, ok = tracker.Add("foobar", 0, koff.LagSample{Time: now.Add(20 * time.Second), Newest: 1199, Committed: 1000})
+	require.True(t, ok)
+	require.Equal(t, int64(200), rates.Lag)
+	require.Equal(t, 0.0, rates.ConsumeRate)
+	require.Equal(t, koff.LagGrowing, rates.Trend)
+	require.Equal(t, time.Duration(-1), rates.ETA)
+
+	// Partitions are tracked separately.
+	_, ok = tracker.Add("foobar", 1, koff.LagSample{Time: now, Newest: 999, Committed: 800})
+	require.False(t, ok)
+}
+
+func TestLagTrackerTrack(t *testing.T) {
+	tracker := koff.NewLagTracker()
+	now := time.Now()
+
+	rates := tracker.Track("foobar", now, map[int32]int64{0: 999, 1: 499, 2: 99}, map[int32]int64{0: 1000, 1: 400, 2: -1})
+	require.Equal(t, 0, len(rates))
+
+	// Partition 2 has no committed offset yet, its first commit must not be counted as consumed messages.
+	rates = tracker.Track("foobar", now.Add(time.Second), map[int32]int64{0: 999, 1: 499, 2: 99}, map[int32]int64{0: 1000, 1: 450, 2: 90})
+	require.Equal(t, 2, len(rates))
+	require.Equal(t, koff.LagStable, rates[0].Trend)
+	require.Equal(t, time.Duration(0), rates[0].ETA)
+	require.Equal(t, koff.LagShrinking, rates[1].Trend)
+	require.Equal(t, time.Second, rates[1].ETA)
+
+	rates = tracker.Track("foobar", now.Add(2*time.Second), map[int32]int64{2: 99}, map[int32]int64{2: 95})
+	require.Equal(t, 5.0, rates[2].ConsumeRate)
+	require.Equal(t, int64(5), rates[2].Lag)
+}